@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+// Функция, которая выполняет операцию из сообщения в свободном слоте агента и возвращает сообщение
+// с ее результатом и трассировкой. У if результат - "1", если условие истинно, и "0", если ложно
+func (a *Agent) calculate(om serialization.OperationMessage) serialization.ResultMessage {
+	slot := a.takeSlot()
+	started := time.Now()
+	var res string
+	var err error
+	if om.Operation == rpn.If {
+		var truth bool
+		truth, err = chooseBranch(om.Mode, om.Operands, om.Timeout)
+		res = "0"
+		if truth {
+			res = "1"
+		}
+	} else {
+		res, err = calculateOperation(om.Mode, om.Operation, om.Operands, om.Timeout)
+	}
+	a.releaseSlot(slot)
+
+	entry := serialization.TraceEntry{
+		Node:      om.Node,
+		Operation: om.Operation,
+		Operands:  om.Operands,
+		Result:    res,
+		Start:     started,
+		End:       time.Now(),
+		Agent:     a.ID,
+		Slot:      slot,
+		Chunk:     om.Chunk,
+	}
+	rm := serialization.ResultMessage{
+		ID:      om.TaskID,
+		Node:    om.Node,
+		Chunk:   om.Chunk,
+		AgentID: a.ID,
+		Result:  res,
+		Status:  storage.StatusTaskCompleted,
+	}
+	if err != nil {
+		entry.Result, entry.Error = "", err.Error()
+		rm.Result, rm.Status, rm.Error = "", storage.StatusTaskInvalid, err.Error()
+		log.Error(err)
+	} else if value, unit, err := rpn.SplitQuantity(res); err == nil && !unit.Empty() {
+		rm.Value, rm.Unit = value, unit.String()
+	}
+	rm.Trace = []serialization.TraceEntry{entry}
+	return rm
+}
+
+// Функция, которая занимает для операции свободный слот агента. Если все слоты заняты, ждет, пока какой-нибудь
+// освободится
+func (a *Agent) takeSlot() int {
+	return <-a.free
+}
+
+// Функция, которая освобождает слот агента после операции
+func (a *Agent) releaseSlot(slot int) {
+	a.free <- slot
+}
+
+// Функция, которая проверяет условие if в заданном режиме и ждет заданный таймаут
+func chooseBranch(mode string, operands []string, timeout time.Duration) (bool, error) {
+	if len(operands) != 1 {
+		return false, fmt.Errorf("Функция %s ожидает одно значение условия, получено %d", rpn.If, len(operands))
+	}
+	truth, err := numeric.Truth(mode, operands[0])
+	if err != nil {
+		return false, err
+	}
+	time.Sleep(timeout)
+	log.Info("goroutine: " + operands[0] + " " + rpn.If + "; result: " + fmt.Sprint(truth))
+	return truth, nil
+}
+
+// Функция, которая вычисляет операцию в один знак в заданном режиме и ждет заданный таймаут.
+// Паника при вычислении превращается в ошибку задачи и не роняет агента
+func calculateOperation(
+	mode, operation string,
+	operands []string,
+	timeout time.Duration,
+) (res string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = "", fmt.Errorf("Ошибка при вычислении операции %s: %v", operation, r)
+		}
+	}()
+	res, err = numeric.Calculate(mode, operation, operands)
+	if err != nil {
+		return "", err
+	}
+	time.Sleep(timeout)
+	log.Info("goroutine: " + strings.Join(operands, " ") + " " + operation + "; result: " + res)
+	return res, nil
+}
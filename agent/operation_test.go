@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		operands  []string
+		mode      string
+		result    string
+		status    string
+		unit      string
+	}{
+		{"add", "+", []string{"2", "3"}, numeric.ModeInt64, "5", storage.StatusTaskCompleted, ""},
+		{"if true", rpn.If, []string{"2"}, numeric.ModeInt64, "1", storage.StatusTaskCompleted, ""},
+		{"if false", rpn.If, []string{"0"}, numeric.ModeInt64, "0", storage.StatusTaskCompleted, ""},
+		{"if with unit", rpn.If, []string{"1 m"}, numeric.ModeDecimal, "", storage.StatusTaskInvalid, ""},
+		{"if arity", rpn.If, []string{"1", "2"}, numeric.ModeInt64, "", storage.StatusTaskInvalid, ""},
+		{"division by zero", "/", []string{"1", "0"}, numeric.ModeInt64, "", storage.StatusTaskInvalid, ""},
+		{"quantity", "+", []string{"1 km", "1 m"}, numeric.ModeDecimal, "1001 m", storage.StatusTaskCompleted, "m"},
+	}
+	a := NewAgent(nil, 1)
+	a.ID = uuid.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			om := serialization.OperationMessage{
+				TaskID:    uuid.New(),
+				Node:      3,
+				Chunk:     2,
+				Operation: tt.operation,
+				Operands:  tt.operands,
+				Mode:      tt.mode,
+			}
+			rm := a.calculate(om)
+			if rm.Result != tt.result || rm.Status != tt.status || rm.Unit != tt.unit {
+				t.Errorf("calculate() = %q %s %q, want %q %s %q", rm.Result, rm.Status, rm.Unit, tt.result, tt.status, tt.unit)
+			}
+			if (rm.Error != "") != (tt.status == storage.StatusTaskInvalid) {
+				t.Errorf("calculate() error = %q, want status %s", rm.Error, tt.status)
+			}
+			if rm.ID != om.TaskID || rm.Node != om.Node || rm.Chunk != om.Chunk || rm.AgentID != a.ID {
+				t.Errorf("calculate() = %+v, does not identify the operation %+v", rm, om)
+			}
+			if len(rm.Trace) != 1 || rm.Trace[0].Node != om.Node || rm.Trace[0].Agent != a.ID {
+				t.Errorf("calculate() trace = %+v, want one entry of the operation", rm.Trace)
+			}
+			if a.Busy() != 0 {
+				t.Errorf("Busy() = %d after the operation, want 0", a.Busy())
+			}
+		})
+	}
+}
+
+// Агент выполняет одновременно не больше операций, чем у него слотов, и каждая занимает свой слот
+func TestCalculateSlots(t *testing.T) {
+	const capacity = 2
+	a := NewAgent(nil, capacity)
+	om := serialization.OperationMessage{Operation: "+", Operands: []string{"1", "1"}, Mode: numeric.ModeInt64, Timeout: 50 * time.Millisecond}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	maxBusy := 0
+	slots := make(map[int]int)
+	for i := 0; i < 3*capacity; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rm := a.calculate(om)
+			mu.Lock()
+			slots[rm.Trace[0].Slot]++
+			if busy := a.Busy(); busy > maxBusy {
+				maxBusy = busy
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxBusy > capacity {
+		t.Errorf("Busy() = %d, want at most %d", maxBusy, capacity)
+	}
+	for slot := range slots {
+		if slot < 0 || slot >= capacity {
+			t.Errorf("operation used slot %d, want 0..%d", slot, capacity-1)
+		}
+	}
+	if a.Busy() != 0 {
+		t.Errorf("Busy() = %d after all operations, want 0", a.Busy())
+	}
+}
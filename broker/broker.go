@@ -0,0 +1,39 @@
+package broker
+
+import "github.com/streadway/amqp"
+
+// Очереди RabbitMQ, через которые общаются оркестратор и агенты
+const (
+	// Операции выражений, которые ждут свободного агента
+	OperationsQueue = "operations_queue"
+	// Сообщения о том, что агент начал считать операцию
+	StatusQueue = "status_queue_v2"
+	// Результаты операций
+	ResultQueue = "result_queue_v2"
+)
+
+// Все очереди, которые объявляются при запуске
+var Queues = []string{OperationsQueue, StatusQueue, ResultQueue}
+
+// Объявляет все очереди. Очереди долговечные, поэтому вместе с постоянными сообщениями переживают
+// перезапуск брокера. Вызывается один раз при запуске оркестратора или агента
+func DeclareTopology(ch *amqp.Channel) error {
+	for _, queue := range Queues {
+		_, err := ch.QueueDeclare(queue, true, false, false, false, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Отправляет в очередь постоянное сообщение в формате json
+func Publish(ch *amqp.Channel, queue string, body []byte) error {
+	return ch.Publish(
+		"",
+		queue,
+		false,
+		false,
+		amqp.Publishing{ContentType: "application/json", DeliveryMode: amqp.Persistent, Body: body},
+	)
+}
@@ -0,0 +1,18 @@
+package broker
+
+import "testing"
+
+// Очереди прежних версий остались в брокерах недолговечными, и объявить их долговечными нельзя
+func TestQueuesDoNotReuseLegacyNames(t *testing.T) {
+	legacy := map[string]bool{"tasks_queue": true, "status_queue": true, "result_queue": true}
+	seen := make(map[string]bool)
+	for _, queue := range Queues {
+		if legacy[queue] {
+			t.Errorf("queue %q reuses the name of a non-durable queue", queue)
+		}
+		if seen[queue] {
+			t.Errorf("queue %q is declared twice", queue)
+		}
+		seen[queue] = true
+	}
+}
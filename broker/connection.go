@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// Задержки между попытками переподключения: после каждой неудачной попытки задержка удваивается
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Ошибка отправки сообщения, пока соединения с брокером нет
+var ErrDisconnected = errors.New("Нет соединения с брокером сообщений")
+
+// Обработчик сообщения из очереди. Обработчик сам подтверждает сообщение или возвращает его в очередь
+type Handler func(d amqp.Delivery)
+
+// Подписка на очередь: при каждом подключении к брокеру заново начинается чтение очереди
+type consumer struct {
+	queue    string
+	prefetch int
+	handler  Handler
+}
+
+// Состояние соединения с брокером: есть ли соединение, с какого момента, сколько раз переподключались
+// и последняя ошибка соединения
+type Status struct {
+	Connected  bool      `json:"connected"`
+	Since      time.Time `json:"since"`
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Соединение с брокером, которое восстанавливается само. Когда соединение или канал закрываются, менеджер
+// переподключается с растущей задержкой, заново объявляет очереди и подписки. Пока соединения нет,
+// Publish сразу возвращает ErrDisconnected, а повторить отправку должен тот, кто ее вызвал
+type Connection struct {
+	url       string
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	channel   *amqp.Channel
+	consumers []consumer
+	status    Status
+	// Соединение закрыто вызовом Close, и переподключаться не нужно
+	closed bool
+}
+
+// Подключается к брокеру и объявляет очереди. Если первое подключение не удалось, возвращает ошибку,
+// а после успешного подключения следит за соединением и восстанавливает его
+func Dial(url string) (*Connection, error) {
+	c := &Connection{url: url}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Функция, которая открывает соединение и канал, объявляет очереди и подписывается на очереди заново.
+// Вызывается только под блокировкой c.mu
+func (c *Connection) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	err = DeclareTopology(ch)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	for _, cons := range c.consumers {
+		err = startConsumer(ch, cons)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	c.conn, c.channel = conn, ch
+	c.status.Connected, c.status.Since = true, time.Now()
+	go c.watch(conn.NotifyClose(make(chan *amqp.Error, 1)), ch.NotifyClose(make(chan *amqp.Error, 1)))
+	return nil
+}
+
+// Горутина, которая ждет закрытия соединения или канала и переподключается
+func (c *Connection) watch(connClosed, channelClosed chan *amqp.Error) {
+	var reason *amqp.Error
+	select {
+	case reason = <-connClosed:
+	case reason = <-channelClosed:
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.status.Connected, c.status.Since = false, time.Now()
+	if reason != nil {
+		c.status.LastError = reason.Error()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn, c.channel = nil, nil
+	c.mu.Unlock()
+	log.Error("Lost connection to RabbitMQ: " + c.Status().LastError)
+	c.reconnect()
+}
+
+// Функция, которая переподключается к брокеру, пока не получится, удваивая задержку между попытками
+func (c *Connection) reconnect() {
+	backoff := minBackoff
+	for {
+		time.Sleep(backoff)
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return
+		}
+		err := c.connect()
+		if err == nil {
+			c.status.Reconnects++
+			c.mu.Unlock()
+			log.Info("Reconnected to RabbitMQ")
+			return
+		}
+		c.status.LastError = err.Error()
+		c.mu.Unlock()
+		log.Error("Error while reconnecting to RabbitMQ: " + err.Error())
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Подписывается на очередь. prefetch - сколько неподтвержденных сообщений брокер отдает сразу (0 - без
+// ограничения). Подписка восстанавливается после каждого переподключения
+func (c *Connection) Consume(queue string, prefetch int, handler Handler) error {
+	cons := consumer{queue: queue, prefetch: prefetch, handler: handler}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumers = append(c.consumers, cons)
+	if c.channel == nil {
+		return nil
+	}
+	return startConsumer(c.channel, cons)
+}
+
+// Функция, которая начинает читать очередь в отдельной горутине. Горутина заканчивается, когда закрывается канал
+func startConsumer(ch *amqp.Channel, cons consumer) error {
+	if cons.prefetch > 0 {
+		err := ch.Qos(cons.prefetch, 0, false)
+		if err != nil {
+			return err
+		}
+	}
+	msgs, err := ch.Consume(cons.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for d := range msgs {
+			cons.handler(d)
+		}
+	}()
+	return nil
+}
+
+// Отправляет в очередь постоянное сообщение в формате json. Пока соединения нет, возвращает ErrDisconnected
+func (c *Connection) Publish(queue string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channel == nil {
+		return ErrDisconnected
+	}
+	return Publish(c.channel, queue, body)
+}
+
+// Возвращает текущее состояние соединения
+func (c *Connection) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Закрывает соединение с брокером
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,21 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Возвращает обработчик запроса состояния сервиса: есть ли соединение с брокером, сколько раз
+// переподключались и последняя ошибка. Пока соединения нет, отвечает кодом 503
+func HealthHandler(c *Connection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := c.Status()
+		code, state := http.StatusOK, "ok"
+		if !status.Connected {
+			code, state = http.StatusServiceUnavailable, "unavailable"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{"status": state, "broker": status})
+	}
+}
@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,32 +31,199 @@ type Agent struct {
 	ID         uuid.UUID `db:"id"`
 	Status     string    `db:"status"`
 	LastOnline string    `db:"last_online"`
+	// Количество слотов агента и сколько из них было занято в последнем пинге
+	Capacity int `db:"capacity"`
+	Busy     int `db:"busy"`
+}
+
+// Значения переменных выражения. В бд хранятся строкой json
+type Variables map[string]string
+
+// Переводит переменные в строку json для записи в бд
+func (v Variables) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[string]string(v))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Читает переменные из строки json, записанной в бд
+func (v *Variables) Scan(src any) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*v = Variables{}
+		return nil
+	case string:
+		b = []byte(src)
+	case []byte:
+		b = src
+	default:
+		return fmt.Errorf("unsupported variables type: %T", src)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Тексты определений пользовательских функций, с которыми выражение было отправлено. В бд хранятся строкой json
+type Functions []string
+
+// Переводит определения функций в строку json для записи в бд
+func (f Functions) Value() (driver.Value, error) {
+	if f == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(f))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Читает определения функций из строки json, записанной в бд
+func (f *Functions) Scan(src any) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*f = Functions{}
+		return nil
+	case string:
+		b = []byte(src)
+	case []byte:
+		b = src
+	default:
+		return fmt.Errorf("unsupported functions type: %T", src)
+	}
+	return json.Unmarshal(b, f)
 }
 
 // Структура задачи, которая хранится в бд
 type Task struct {
 	ID         uuid.UUID `db:"id"`
 	Expression string    `db:"expression"`
+	Mode       string    `db:"mode"`
+	Notation   string    `db:"notation"`
+	Variables  Variables `db:"variables"`
+	Functions  Functions `db:"functions"`
 	Status     string    `db:"status"`
 	Result     string    `db:"result"`
-	AgentID    uuid.UUID `db:"agent_id"`
+	// Единица измерения результата, если она есть. Result записан вместе с ней
+	Unit string `db:"unit"`
+	// Агент, который последним начал считать операцию задачи
+	AgentID uuid.UUID `db:"agent_id"`
+	Error   string    `db:"error"`
+	// Оптимизированное выражение, операции которого оркестратор отправляет агентам
+	Optimized string `db:"optimized"`
+	// Длина критического пути оптимизированного выражения в операциях
+	CriticalPath int `db:"critical_path"`
+	// Количество операций и оценка времени вычисления в миллисекундах на момент добавления задачи
+	Operations int   `db:"operations"`
+	Estimated  int64 `db:"estimated_ms"`
+	// Ход вычисления по последней выполненной операции: сколько операций выполнено, сколько осталось,
+	// сколько миллисекунд оставалось и когда она выполнена
+	CompletedOperations int    `db:"completed_operations"`
+	RemainingOperations int    `db:"remaining_operations"`
+	RemainingTime       int64  `db:"remaining_ms"`
+	ProgressAt          string `db:"progress_at"`
 }
 
-// Записывает задачу в бд
-func (s *Storage) AddTask(expression string) (uuid.UUID, error) {
-	task := &Task{
-		ID:         uuid.New(),
-		Expression: expression,
-		Status:     StatusTaskAccepted,
-		Result:     "",
-		AgentID:    uuid.Nil,
+// Формат времени операций трассировки. Время хранится в UTC с фиксированным числом знаков,
+// поэтому сортировка строк совпадает с сортировкой по времени
+const TraceTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Операнды операции из трассировки. В бд хранятся строкой json
+type Operands []string
+
+// Переводит операнды в строку json для записи в бд
+func (o Operands) Value() (driver.Value, error) {
+	if o == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(o))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Читает операнды из строки json, записанной в бд
+func (o *Operands) Scan(src any) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*o = Operands{}
+		return nil
+	case string:
+		b = []byte(src)
+	case []byte:
+		b = src
+	default:
+		return fmt.Errorf("unsupported operands type: %T", src)
 	}
+	return json.Unmarshal(b, o)
+}
+
+// Операция, которую выполнил агент при вычислении задачи: номер узла, операнды, результат или ошибка,
+// время начала и конца (TraceTimeLayout), агент, слот, который занимала операция на агенте, и номер части,
+// если операция над векторами вычислялась по частям
+type TraceEntry struct {
+	TaskID     uuid.UUID `db:"task_id"`
+	Node       int       `db:"node"`
+	Operation  string    `db:"operation"`
+	Operands   Operands  `db:"operands"`
+	Result     string    `db:"result"`
+	Error      string    `db:"error"`
+	StartedAt  string    `db:"started_at"`
+	FinishedAt string    `db:"finished_at"`
+	AgentID    uuid.UUID `db:"agent_id"`
+	Slot       int       `db:"slot"`
+	Chunk      int       `db:"chunk"`
+}
+
+// Структура пользовательской функции, которая хранится в бд
+type Function struct {
+	Name       string `db:"name"`
+	Definition string `db:"definition"`
+}
+
+// Записывает задачу в бд. Из переданной задачи берутся выражение, его запись, режим, значения переменных,
+// определения функций, оптимизированное выражение и оценка времени вычисления, остальные поля заполняются заново
+func (s *Storage) AddTask(task Task) (uuid.UUID, error) {
+	task.ID = uuid.New()
+	task.Status = StatusTaskAccepted
+	task.Result = ""
+	task.AgentID = uuid.Nil
+	task.Error = ""
+	task.CompletedOperations = 0
+	task.RemainingOperations = task.Operations
+	task.RemainingTime = task.Estimated
+	task.ProgressAt = ""
 	_, err := s.db.Exec(
-		"INSERT INTO tasks (id, expression, status, result) VALUES ($1, $2, $3, $4)",
+		`INSERT INTO tasks (
+			id, expression, mode, notation, variables, functions, status, result, error, optimized, critical_path,
+			operations, estimated_ms, completed_operations, remaining_operations, remaining_ms, progress_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
 		task.ID,
 		task.Expression,
+		task.Mode,
+		task.Notation,
+		task.Variables,
+		task.Functions,
 		task.Status,
 		task.Result,
+		task.Error,
+		task.Optimized,
+		task.CriticalPath,
+		task.Operations,
+		task.Estimated,
+		task.CompletedOperations,
+		task.RemainingOperations,
+		task.RemainingTime,
+		task.ProgressAt,
 	)
 	if err != nil {
 		return uuid.Nil, err
@@ -70,9 +240,38 @@ func (s *Storage) UpdateTaskStatus(id uuid.UUID, status string) error {
 	return nil
 }
 
-// Обновляет результат задачи в бд
-func (s *Storage) UpdateTaskResult(id uuid.UUID, res string) error {
-	_, err := s.db.Exec("UPDATE tasks SET result=$1 WHERE id=$2", res, id)
+// Сбрасывает ход вычисления задачи, когда агент начинает ее считать
+func (s *Storage) StartTaskProgress(id uuid.UUID, at time.Time) error {
+	_, err := s.db.Exec(
+		`UPDATE tasks SET completed_operations=0, remaining_operations=operations, remaining_ms=estimated_ms,
+		progress_at=$1 WHERE id=$2`,
+		at.Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Обновляет ход вычисления задачи. Сообщения, пришедшие после результата, не учитываются
+func (s *Storage) UpdateTaskProgress(
+	id uuid.UUID,
+	completed, remaining int,
+	remainingTime time.Duration,
+	at time.Time,
+) error {
+	_, err := s.db.Exec(
+		`UPDATE tasks SET completed_operations=$1, remaining_operations=$2, remaining_ms=$3, progress_at=$4
+		WHERE id=$5 AND status NOT IN ($6, $7)`,
+		completed,
+		remaining,
+		remainingTime.Milliseconds(),
+		at.Format(time.RFC3339Nano),
+		id,
+		StatusTaskCompleted,
+		StatusTaskInvalid,
+	)
 	if err != nil {
 		return err
 	}
@@ -108,19 +307,37 @@ func (s *Storage) GetAllTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-// Добавляет агента в бд
-func (s *Storage) AddAgent() (uuid.UUID, error) {
+// Возвращает задачи, вычисление которых еще не закончено
+func (s *Storage) GetUnfinishedTasks() ([]Task, error) {
+	var tasks []Task
+	err := s.db.Select(
+		&tasks,
+		"SELECT * FROM tasks WHERE status NOT IN ($1, $2)",
+		StatusTaskCompleted,
+		StatusTaskInvalid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Добавляет агента с заданным количеством слотов в бд
+func (s *Storage) AddAgent(capacity int) (uuid.UUID, error) {
 	agent := &Agent{
 		ID:         uuid.New(),
 		Status:     StatusAgentActive,
 		LastOnline: time.Now().Format(time.RFC1123Z),
+		Capacity:   capacity,
 	}
 
 	_, err := s.db.Exec(
-		"INSERT INTO agents (id, status, last_online) VALUES ($1, $2, $3)",
+		"INSERT INTO agents (id, status, last_online, capacity, busy) VALUES ($1, $2, $3, $4, $5)",
 		agent.ID,
 		agent.Status,
 		agent.LastOnline,
+		agent.Capacity,
+		agent.Busy,
 	)
 	if err != nil {
 		return uuid.Nil, err
@@ -128,16 +345,6 @@ func (s *Storage) AddAgent() (uuid.UUID, error) {
 	return agent.ID, nil
 }
 
-// Возвращает агента по его айди
-func (s *Storage) GetAgentById(id uuid.UUID) ([]Agent, error) {
-	var agents []Agent
-	err := s.db.Select(&agents, "SELECT * FROM agents WHERE id=$1", id)
-	if err != nil {
-		return nil, err
-	}
-	return agents, nil
-}
-
 // Возвращает всех агентов из бд
 func (s *Storage) GetAllAgents() ([]Agent, error) {
 	var agents []Agent
@@ -167,6 +374,130 @@ func (s *Storage) UpdateAgentLastOnline(id uuid.UUID, lastOnline time.Time) erro
 	return nil
 }
 
+// Обновляет количество слотов агента и сколько из них занято
+func (s *Storage) UpdateAgentLoad(id uuid.UUID, capacity, busy int) error {
+	_, err := s.db.Exec("UPDATE agents SET capacity=$1, busy=$2 WHERE id=$3", capacity, busy, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Сохраняет пользовательскую функцию в бд, заменяя прежнее определение с тем же именем
+func (s *Storage) SaveFunction(name, definition string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO functions (name, definition) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET definition=excluded.definition`,
+		name,
+		definition,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Возвращает все пользовательские функции из бд
+func (s *Storage) GetAllFunctions() ([]Function, error) {
+	var functions []Function
+	err := s.db.Select(&functions, "SELECT * FROM functions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	return functions, nil
+}
+
+// Удаляет пользовательскую функцию из бд
+func (s *Storage) DeleteFunction(name string) error {
+	_, err := s.db.Exec("DELETE FROM functions WHERE name=$1", name)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Записывает в одной транзакции итог вычисления задачи: статус, результат с единицей измерения, ошибку
+// и трассировку. Задача, которая уже закончена, не меняется, поэтому повторная запись того же итога безопасна
+func (s *Storage) FinishTask(id uuid.UUID, status, res, unit, message string, entries []TraceEntry) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	updated, err := tx.Exec(
+		`UPDATE tasks SET status=$1, result=$2, unit=$3, error=$4
+		WHERE id=$5 AND status NOT IN ($6, $7)`,
+		status,
+		res,
+		unit,
+		message,
+		id,
+		StatusTaskCompleted,
+		StatusTaskInvalid,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := updated.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return nil
+	}
+	err = writeTrace(tx, id, entries)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Заменяет трассировку задачи внутри транзакции
+func writeTrace(tx *sqlx.Tx, taskID uuid.UUID, entries []TraceEntry) error {
+	_, err := tx.Exec("DELETE FROM traces WHERE task_id=$1", taskID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		_, err = tx.Exec(
+			`INSERT INTO traces (
+				task_id, node, operation, operands, result, error, started_at, finished_at, agent_id, slot, chunk
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			taskID,
+			entry.Node,
+			entry.Operation,
+			entry.Operands,
+			entry.Result,
+			entry.Error,
+			entry.StartedAt,
+			entry.FinishedAt,
+			entry.AgentID,
+			entry.Slot,
+			entry.Chunk,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Возвращает трассировку задачи в порядке начала операций
+func (s *Storage) GetTrace(taskID uuid.UUID) ([]TraceEntry, error) {
+	var entries []TraceEntry
+	err := s.db.Select(
+		&entries,
+		`SELECT task_id, node, operation, operands, result, error, started_at, finished_at, agent_id, slot, chunk
+		FROM traces WHERE task_id=$1 ORDER BY started_at, node, chunk`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 // Возвращает новый экземпляр хранилища
 func NewStorage(db *sqlx.DB) *Storage {
 	return &Storage{
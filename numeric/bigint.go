@@ -0,0 +1,101 @@
+package numeric
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Целочисленная арифметика произвольной точности, деление отбрасывает дробную часть
+type bigIntArithmetic struct{}
+
+func (bigIntArithmetic) Parse(s string) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("Некорректное число: %s", s)
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("Дробное число %s в целочисленном режиме", s)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+func (bigIntArithmetic) Format(v *big.Int) string {
+	return v.String()
+}
+
+func (bigIntArithmetic) Neg(a *big.Int) (*big.Int, error) {
+	return new(big.Int).Neg(a), nil
+}
+
+func (bigIntArithmetic) Add(a, b *big.Int) (*big.Int, error) {
+	return new(big.Int).Add(a, b), nil
+}
+
+func (bigIntArithmetic) Sub(a, b *big.Int) (*big.Int, error) {
+	return new(big.Int).Sub(a, b), nil
+}
+
+func (bigIntArithmetic) Mul(a, b *big.Int) (*big.Int, error) {
+	return new(big.Int).Mul(a, b), nil
+}
+
+func (bigIntArithmetic) Div(a, b *big.Int) (*big.Int, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	return new(big.Int).Quo(a, b), nil
+}
+
+func (bigIntArithmetic) IntDiv(a, b *big.Int) (*big.Int, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	return floorRat(new(big.Rat).SetFrac(a, b)), nil
+}
+
+func (bigIntArithmetic) Mod(a, b *big.Int) (*big.Int, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	quotient := floorRat(new(big.Rat).SetFrac(a, b))
+	return new(big.Int).Sub(a, quotient.Mul(quotient, b)), nil
+}
+
+func (bigIntArithmetic) Pow(a, b *big.Int) (*big.Int, error) {
+	if b.Sign() < 0 {
+		return nil, fmt.Errorf("Отрицательная степень %s в целочисленном режиме", b)
+	}
+	res, err := powRat(new(big.Rat).SetInt(a), b)
+	if err != nil {
+		return nil, err
+	}
+	return res.Num(), nil
+}
+
+func (bigIntArithmetic) Abs(a *big.Int) (*big.Int, error) {
+	return new(big.Int).Abs(a), nil
+}
+
+// Извлекает целую часть квадратного корня
+func (bigIntArithmetic) Sqrt(a *big.Int) (*big.Int, error) {
+	if a.Sign() < 0 {
+		return nil, ErrNegativeRoot
+	}
+	return new(big.Int).Sqrt(a), nil
+}
+
+// Округляет до десятков, сотен и тд при отрицательном digits, иначе возвращает само число
+func (bigIntArithmetic) Round(a, digits *big.Int) (*big.Int, error) {
+	n, err := digitsRat(new(big.Rat).SetInt(digits))
+	if err != nil {
+		return nil, err
+	}
+	if n >= 0 {
+		return a, nil
+	}
+	return roundRat(new(big.Rat).SetInt(a), n).Num(), nil
+}
+
+func (bigIntArithmetic) Cmp(a, b *big.Int) (int, error) {
+	return a.Cmp(b), nil
+}
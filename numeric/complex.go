@@ -0,0 +1,175 @@
+package numeric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Наибольшая по модулю целая степень, которая считается умножениями. При больших степенях промежуточные
+// результаты переполняются и дают неопределенную мнимую часть, поэтому они считаются через cmplx.Pow
+const maxComplexPowExponent = 1024
+
+// Ошибки комплексной арифметики
+var (
+	ErrUnordered = errors.New("Комплексные числа с ненулевой мнимой частью нельзя сравнивать")
+	ErrNotReal   = errors.New("Операция определена только для действительных чисел")
+)
+
+// Арифметика комплексных чисел двойной точности. Числа записываются как "3+4i", "-2.5i" или "7",
+// мнимая единица - "i"
+type complexArithmetic struct{}
+
+func (complexArithmetic) Parse(s string) (complex128, error) {
+	if s == "i" {
+		return 1i, nil
+	}
+	// "3+i" и "-i" пишутся без единицы, а ParseComplex ее требует
+	if strings.HasSuffix(s, "+i") || strings.HasSuffix(s, "-i") {
+		s = s[:len(s)-1] + "1i"
+	}
+	// ParseComplex допускает скобки и подчеркивания между цифрами, а в выражениях их нет
+	if strings.ContainsAny(s, "()_") {
+		return 0, fmt.Errorf("Некорректное число: %s", s)
+	}
+	v, err := strconv.ParseComplex(s, 128)
+	if err != nil {
+		return 0, fmt.Errorf("Некорректное число: %s", s)
+	}
+	return v, nil
+}
+
+func (complexArithmetic) Format(v complex128) string {
+	re := strconv.FormatFloat(real(v), 'g', -1, 64)
+	if imag(v) == 0 {
+		return re
+	}
+	im := strconv.FormatFloat(imag(v), 'g', -1, 64) + "i"
+	if real(v) == 0 {
+		return im
+	}
+	if !strings.HasPrefix(im, "-") && !strings.HasPrefix(im, "+") {
+		im = "+" + im
+	}
+	return re + im
+}
+
+func (complexArithmetic) Neg(a complex128) (complex128, error) {
+	return -a, nil
+}
+
+func (complexArithmetic) Add(a, b complex128) (complex128, error) {
+	return a + b, nil
+}
+
+func (complexArithmetic) Sub(a, b complex128) (complex128, error) {
+	return a - b, nil
+}
+
+func (complexArithmetic) Mul(a, b complex128) (complex128, error) {
+	return defined(a * b)
+}
+
+func (complexArithmetic) Div(a, b complex128) (complex128, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return defined(a / b)
+}
+
+func (complexArithmetic) IntDiv(a, b complex128) (complex128, error) {
+	return 0, ErrNotReal
+}
+
+func (complexArithmetic) Mod(a, b complex128) (complex128, error) {
+	return 0, ErrNotReal
+}
+
+// Целую степень считает умножениями, чтобы, например, i^2 было ровно -1: cmplx.Pow считает через
+// логарифм и оставляет погрешность в мнимой части
+func (complexArithmetic) Pow(a, b complex128) (complex128, error) {
+	if a == 0 && real(b) < 0 {
+		return 0, ErrDivisionByZero
+	}
+	n := real(b)
+	if imag(b) != 0 || n != math.Trunc(n) || math.Abs(n) > maxComplexPowExponent {
+		return defined(cmplx.Pow(a, b))
+	}
+	res, base, exponent := complex128(1), a, int64(math.Abs(n))
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			res *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	if n < 0 {
+		res = 1 / res
+	}
+	return defined(res)
+}
+
+func (complexArithmetic) Abs(a complex128) (complex128, error) {
+	return complex(cmplx.Abs(a), 0), nil
+}
+
+// Главное значение корня: корень из отрицательного числа - мнимое число
+func (complexArithmetic) Sqrt(a complex128) (complex128, error) {
+	return cmplx.Sqrt(a), nil
+}
+
+// Округляет действительную и мнимую части по отдельности
+func (c complexArithmetic) Round(a, digits complex128) (complex128, error) {
+	if imag(digits) != 0 {
+		return 0, ErrDigits
+	}
+	var f float64Arithmetic
+	re, err := f.Round(real(a), real(digits))
+	if err != nil {
+		return 0, err
+	}
+	im, err := f.Round(imag(a), real(digits))
+	if err != nil {
+		return 0, err
+	}
+	return complex(re, im), nil
+}
+
+// Сравнивает числа с нулевой мнимой частью. Остальные числа упорядочить нельзя
+func (complexArithmetic) Cmp(a, b complex128) (int, error) {
+	if imag(a) != 0 || imag(b) != 0 {
+		return 0, ErrUnordered
+	}
+	return float64Arithmetic{}.Cmp(real(a), real(b))
+}
+
+// Проверяет числа на равенство: в отличие от Cmp, работает для любых комплексных чисел
+func (complexArithmetic) Equal(a, b complex128) (bool, error) {
+	if cmplx.IsNaN(a) || cmplx.IsNaN(b) {
+		return false, ErrUndefined
+	}
+	return a == b, nil
+}
+
+func (complexArithmetic) Conj(a complex128) (complex128, error) {
+	return cmplx.Conj(a), nil
+}
+
+func (complexArithmetic) Re(a complex128) (complex128, error) {
+	return complex(real(a), 0), nil
+}
+
+func (complexArithmetic) Im(a complex128) (complex128, error) {
+	return complex(imag(a), 0), nil
+}
+
+// Возвращает ошибку, если у результата неопределенная действительная или мнимая часть
+func defined(v complex128) (complex128, error) {
+	if math.IsNaN(real(v)) || math.IsNaN(imag(v)) {
+		return 0, ErrUndefined
+	}
+	return v, nil
+}
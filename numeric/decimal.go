@@ -0,0 +1,122 @@
+package numeric
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Точная арифметика рациональных чисел. Результат выводится десятичной дробью, если она конечна,
+// и обыкновенной дробью ("1/3") в остальных случаях
+type decimalArithmetic struct{}
+
+func (decimalArithmetic) Parse(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("Некорректное число: %s", s)
+	}
+	return r, nil
+}
+
+func (decimalArithmetic) Format(v *big.Rat) string {
+	if v.IsInt() {
+		return v.Num().String()
+	}
+	// Дробь конечна, если знаменатель раскладывается только на двойки и пятерки
+	denominator := new(big.Int).Set(v.Denom())
+	digits := 0
+	for _, p := range []int64{2, 5} {
+		prime := big.NewInt(p)
+		count := 0
+		mod := new(big.Int)
+		for {
+			quo, rem := new(big.Int).QuoRem(denominator, prime, mod)
+			if rem.Sign() != 0 {
+				break
+			}
+			denominator = quo
+			count++
+		}
+		if count > digits {
+			digits = count
+		}
+	}
+	if denominator.Cmp(big.NewInt(1)) != 0 {
+		return v.RatString()
+	}
+	return v.FloatString(digits)
+}
+
+func (decimalArithmetic) Neg(a *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Neg(a), nil
+}
+
+func (decimalArithmetic) Add(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Add(a, b), nil
+}
+
+func (decimalArithmetic) Sub(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Sub(a, b), nil
+}
+
+func (decimalArithmetic) Mul(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Mul(a, b), nil
+}
+
+func (decimalArithmetic) Div(a, b *big.Rat) (*big.Rat, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	return new(big.Rat).Quo(a, b), nil
+}
+
+func (decimalArithmetic) IntDiv(a, b *big.Rat) (*big.Rat, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	return new(big.Rat).SetInt(floorRat(new(big.Rat).Quo(a, b))), nil
+}
+
+func (d decimalArithmetic) Mod(a, b *big.Rat) (*big.Rat, error) {
+	quotient, err := d.IntDiv(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Sub(a, quotient.Mul(quotient, b)), nil
+}
+
+func (decimalArithmetic) Pow(a, b *big.Rat) (*big.Rat, error) {
+	if !b.IsInt() {
+		return nil, ErrFractionalPow
+	}
+	return powRat(a, b.Num())
+}
+
+func (decimalArithmetic) Abs(a *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Abs(a), nil
+}
+
+// Извлекает корень, только если он выражается точно (числитель и знаменатель - полные квадраты)
+func (decimalArithmetic) Sqrt(a *big.Rat) (*big.Rat, error) {
+	if a.Sign() < 0 {
+		return nil, ErrNegativeRoot
+	}
+	num := new(big.Int).Sqrt(a.Num())
+	denom := new(big.Int).Sqrt(a.Denom())
+	res := new(big.Rat).SetFrac(num, denom)
+	if new(big.Rat).Mul(res, res).Cmp(a) != 0 {
+		return nil, fmt.Errorf("Корень из %s не выражается точно", a.RatString())
+	}
+	return res, nil
+}
+
+func (decimalArithmetic) Round(a, digits *big.Rat) (*big.Rat, error) {
+	n, err := digitsRat(digits)
+	if err != nil {
+		return nil, err
+	}
+	return roundRat(a, n), nil
+}
+
+func (decimalArithmetic) Cmp(a, b *big.Rat) (int, error) {
+	return a.Cmp(b), nil
+}
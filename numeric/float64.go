@@ -0,0 +1,111 @@
+package numeric
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Арифметика чисел с плавающей точкой двойной точности
+type float64Arithmetic struct{}
+
+func (float64Arithmetic) Parse(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Некорректное число: %s", s)
+	}
+	return v, nil
+}
+
+func (float64Arithmetic) Format(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (float64Arithmetic) Neg(a float64) (float64, error) {
+	return -a, nil
+}
+
+func (float64Arithmetic) Add(a, b float64) (float64, error) {
+	return a + b, nil
+}
+
+func (float64Arithmetic) Sub(a, b float64) (float64, error) {
+	return a - b, nil
+}
+
+func (float64Arithmetic) Mul(a, b float64) (float64, error) {
+	return a * b, nil
+}
+
+func (float64Arithmetic) Div(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return a / b, nil
+}
+
+func (float64Arithmetic) IntDiv(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return math.Floor(a / b), nil
+}
+
+func (float64Arithmetic) Mod(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return a - b*math.Floor(a/b), nil
+}
+
+func (float64Arithmetic) Pow(a, b float64) (float64, error) {
+	res := math.Pow(a, b)
+	if math.IsNaN(res) {
+		return 0, ErrUndefined
+	}
+	return res, nil
+}
+
+func (float64Arithmetic) Abs(a float64) (float64, error) {
+	return math.Abs(a), nil
+}
+
+func (float64Arithmetic) Sqrt(a float64) (float64, error) {
+	if a < 0 {
+		return 0, ErrNegativeRoot
+	}
+	return math.Sqrt(a), nil
+}
+
+func (float64Arithmetic) Round(a, digits float64) (float64, error) {
+	if digits != math.Trunc(digits) || math.Abs(digits) > maxRoundDigits {
+		return 0, ErrDigits
+	}
+	if a == 0 || math.IsInf(a, 0) || math.IsNaN(a) {
+		return a, nil
+	}
+	scale := math.Pow(10, digits)
+	scaled := a * scale
+	switch {
+	// Знаков больше, чем точность float64: число уже округлено, а 10^digits может не поместиться в float64
+	case math.IsInf(scaled, 0) || math.Abs(scaled) >= 1<<52:
+		return a, nil
+	// 10^digits меньше наименьшего float64: любое число округляется до нуля
+	case scale == 0:
+		return math.Copysign(0, a), nil
+	}
+	return math.Round(scaled) / scale, nil
+}
+
+func (float64Arithmetic) Cmp(a, b float64) (int, error) {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return 0, ErrUndefined
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
@@ -0,0 +1,40 @@
+package numeric
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFloat64Round(t *testing.T) {
+	tests := []struct {
+		mode     string
+		operands []string
+		want     string
+		err      error
+	}{
+		{ModeFloat64, []string{"1.25", "1"}, "1.3", nil},
+		{ModeFloat64, []string{"1234.5", "-2"}, "1200", nil},
+		{ModeFloat64, []string{"1.5", "400"}, "1.5", nil},
+		{ModeFloat64, []string{"1.5", "310"}, "1.5", nil},
+		{ModeFloat64, []string{"0.1", "300"}, "0.1", nil},
+		{ModeFloat64, []string{"1e-320", "400"}, "1e-320", nil},
+		{ModeFloat64, []string{"0", "400"}, "0", nil},
+		{ModeFloat64, []string{"1.5", "-400"}, "0", nil},
+		{ModeFloat64, []string{"-1e300", "-400"}, "-0", nil},
+		{ModeFloat64, []string{"1e300", "-310"}, "0", nil},
+		{ModeFloat64, []string{"1.5", "1001"}, "", ErrDigits},
+		{ModeFloat64, []string{"1.5", "0.5"}, "", ErrDigits},
+		{ModeComplex, []string{"1.5+2.5i", "400"}, "1.5+2.5i", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.operands[0]+" "+tt.operands[1], func(t *testing.T) {
+			got, err := Calculate(tt.mode, "round", tt.operands)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Calculate() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Calculate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package numeric
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Целочисленная арифметика, деление отбрасывает дробную часть.
+// Если результат не помещается в int64, операция возвращает ErrOverflow
+type int64Arithmetic struct{}
+
+// Разбирает целое число. Допускается экспоненциальная запись, если число целое ("1e3")
+func (int64Arithmetic) Parse(s string) (int64, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("Некорректное число: %s", s)
+	}
+	if !r.IsInt() {
+		return 0, fmt.Errorf("Дробное число %s в целочисленном режиме", s)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("Число %s не помещается в int64", s)
+	}
+	return r.Num().Int64(), nil
+}
+
+func (int64Arithmetic) Format(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func (int64Arithmetic) Neg(a int64) (int64, error) {
+	if a == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return -a, nil
+}
+
+func (int64Arithmetic) Add(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+func (int64Arithmetic) Sub(a, b int64) (int64, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrOverflow
+	}
+	return a - b, nil
+}
+
+func (int64Arithmetic) Mul(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	res := a * b
+	if res/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrOverflow
+	}
+	return res, nil
+}
+
+func (int64Arithmetic) Div(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	if a == math.MinInt64 && b == -1 {
+		return 0, ErrOverflow
+	}
+	return a / b, nil
+}
+
+func (int64Arithmetic) IntDiv(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	if a == math.MinInt64 && b == -1 {
+		return 0, ErrOverflow
+	}
+	res := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		res--
+	}
+	return res, nil
+}
+
+func (int64Arithmetic) Mod(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	res := a % b
+	if res != 0 && (res < 0) != (b < 0) {
+		res += b
+	}
+	return res, nil
+}
+
+func (i int64Arithmetic) Pow(a, b int64) (int64, error) {
+	if b < 0 {
+		return 0, fmt.Errorf("Отрицательная степень %d в целочисленном режиме", b)
+	}
+	res := int64(1)
+	for b > 0 {
+		var err error
+		if b&1 == 1 {
+			res, err = i.Mul(res, a)
+			if err != nil {
+				return 0, err
+			}
+		}
+		b >>= 1
+		if b > 0 {
+			a, err = i.Mul(a, a)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func (i int64Arithmetic) Abs(a int64) (int64, error) {
+	if a < 0 {
+		return i.Neg(a)
+	}
+	return a, nil
+}
+
+// Извлекает целую часть квадратного корня
+func (int64Arithmetic) Sqrt(a int64) (int64, error) {
+	if a < 0 {
+		return 0, ErrNegativeRoot
+	}
+	return new(big.Int).Sqrt(big.NewInt(a)).Int64(), nil
+}
+
+// Округляет до десятков, сотен и тд при отрицательном digits, иначе возвращает само число
+func (int64Arithmetic) Round(a, digits int64) (int64, error) {
+	n, err := digitsRat(new(big.Rat).SetInt64(digits))
+	if err != nil {
+		return 0, err
+	}
+	if n >= 0 {
+		return a, nil
+	}
+	res := roundRat(new(big.Rat).SetInt64(a), n).Num()
+	if !res.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return res.Int64(), nil
+}
+
+func (int64Arithmetic) Cmp(a, b int64) (int, error) {
+	switch {
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
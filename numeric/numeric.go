@@ -0,0 +1,451 @@
+package numeric
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/oleg-top/go-orchestrator/rpn"
+)
+
+// Режимы вычислений
+const (
+	ModeInt64   = "int64"
+	ModeBigInt  = "bigint"
+	ModeFloat64 = "float64"
+	ModeDecimal = "decimal"
+	ModeComplex = "complex"
+)
+
+// Режим вычислений, который используется, если режим не указан
+const DefaultMode = ModeInt64
+
+// Ошибки вычислений
+var (
+	ErrDivisionByZero = errors.New("Деление на ноль")
+	ErrOverflow       = errors.New("Переполнение: результат не помещается в int64")
+	ErrNegativeRoot   = errors.New("Корень из отрицательного числа")
+	ErrUndefined      = errors.New("Результат операции не определен")
+)
+
+// Арифметика одного режима вычислений над значениями типа T
+type arithmetic[T any] interface {
+	Parse(s string) (T, error)
+	Format(v T) string
+	Neg(a T) (T, error)
+	Add(a, b T) (T, error)
+	Sub(a, b T) (T, error)
+	Mul(a, b T) (T, error)
+	Div(a, b T) (T, error)
+	// Целочисленное деление с округлением вниз
+	IntDiv(a, b T) (T, error)
+	// Остаток от деления, знак которого совпадает со знаком делителя: a = b * IntDiv(a, b) + Mod(a, b)
+	Mod(a, b T) (T, error)
+	Pow(a, b T) (T, error)
+	Abs(a T) (T, error)
+	Sqrt(a T) (T, error)
+	// Округление до digits знаков после запятой (отрицательное digits округляет до десятков, сотен и тд)
+	Round(a, digits T) (T, error)
+	Cmp(a, b T) (int, error)
+}
+
+// Арифметика, в которой не все значения можно упорядочить, но любые можно проверить на равенство.
+// Тогда == и != и проверка условий используют Equal, а не Cmp
+type equality[T any] interface {
+	Equal(a, b T) (bool, error)
+}
+
+// Арифметика комплексных чисел: сопряженное число, действительная и мнимая части
+type complexParts[T any] interface {
+	Conj(a T) (T, error)
+	Re(a T) (T, error)
+	Im(a T) (T, error)
+}
+
+// Вычислитель, скрывающий за собой тип значений конкретного режима
+type calculator interface {
+	parse(literal string) error
+	calculate(operation string, operands []string) (string, error)
+	normalize(literal string) (string, error)
+	truth(value string) (bool, error)
+}
+
+// Все поддерживаемые режимы вычислений
+var modes = map[string]calculator{
+	ModeInt64:   typedCalculator[int64]{int64Arithmetic{}},
+	ModeBigInt:  typedCalculator[*big.Int]{bigIntArithmetic{}},
+	ModeFloat64: typedCalculator[float64]{float64Arithmetic{}},
+	ModeDecimal: typedCalculator[*big.Rat]{decimalArithmetic{}},
+	ModeComplex: typedCalculator[complex128]{complexArithmetic{}},
+}
+
+// Проверяет, поддерживается ли режим вычислений. Пустой режим означает режим по умолчанию
+func IsMode(mode string) bool {
+	_, ok := modes[normalize(mode)]
+	return ok
+}
+
+// Возвращает названия всех поддерживаемых режимов
+func Modes() []string {
+	return []string{ModeInt64, ModeBigInt, ModeFloat64, ModeDecimal, ModeComplex}
+}
+
+// Проверяет, что в режиме вычислений нет бесконечностей и NaN, то есть произведение числа на ноль всегда равно нулю
+func IsExact(mode string) bool {
+	mode = normalize(mode)
+	return mode == ModeInt64 || mode == ModeBigInt || mode == ModeDecimal
+}
+
+// Проверяет, что в режиме вычислений ни результат сложения и умножения, ни ошибка не зависят от порядка операций.
+// В int64 от порядка зависит, случится ли переполнение в промежуточном результате, поэтому int64 не подходит
+func IsAssociative(mode string) bool {
+	mode = normalize(mode)
+	return mode == ModeBigInt || mode == ModeDecimal
+}
+
+// Проверяет, что литерал является корректным числом в заданном режиме. После числа может стоять
+// единица измерения: "5 m", "9.8 m/s^2"
+func ParseLiteral(mode, literal string) error {
+	c, err := getCalculator(mode)
+	if err != nil {
+		return err
+	}
+	literal, _, err = rpn.SplitQuantity(literal)
+	if err != nil {
+		return err
+	}
+	if isTensorLiteral(literal) {
+		_, err := normalizeTensor(c, literal)
+		return err
+	}
+	if normalize(mode) != ModeComplex && strings.HasSuffix(literal, "i") {
+		return fmt.Errorf("Мнимые числа поддерживаются только в режиме %s: %s", ModeComplex, literal)
+	}
+	return c.parse(literal)
+}
+
+// Приводит число к записи, в которой режим выводит результаты, например "2.50" к "2.5" в режиме decimal.
+// Векторы и матрицы приводятся поэлементно, единица измерения записывается в каноническом виде
+func Normalize(mode, literal string) (string, error) {
+	c, err := getCalculator(mode)
+	if err != nil {
+		return "", err
+	}
+	return normalizeQuantity(c, literal)
+}
+
+// Вычисляет операцию над операндами в заданном режиме. Операция задается так же, как она хранится
+// в дереве выражения: знаком ("+", "-", "*", "/", "//", "%", "^", "<", "<=", "==", "!=", ">=", ">")
+// или названием ("neg" для унарного минуса, "and", "or", "not" для логических операций,
+// "abs", "sqrt", "min", "max", "round", "conj", "re", "im" для функций). Сравнения и логические операции
+// возвращают 1 или 0. Операнды могут быть векторами и матрицами ("[1, 2]", "[[1, 2], [3, 4]]"), над которыми
+// определены поэлементные операции и функции "vector", "dot", "matmul", "transpose", "sum". У операндов могут
+// быть единицы измерения ("5 m"), тогда единица есть и у результата, а "to" и "si" переводят величину
+// в другие единицы
+func Calculate(mode, operation string, operands []string) (string, error) {
+	c, err := getCalculator(mode)
+	if err != nil {
+		return "", err
+	}
+	if operation == rpn.To || operation == rpn.SI {
+		return calculateQuantity(c, operation, operands)
+	}
+	for _, operand := range operands {
+		if hasUnit(operand) {
+			return calculateQuantity(c, operation, operands)
+		}
+	}
+	return calculateValue(c, operation, operands)
+}
+
+// Вычисляет операцию над числами, векторами и матрицами без единиц измерения
+func calculateValue(c calculator, operation string, operands []string) (string, error) {
+	if _, ok := tensorOperations[operation]; ok {
+		return calculateTensor(c, operation, operands)
+	}
+	for _, operand := range operands {
+		if isTensorLiteral(operand) {
+			return calculateTensor(c, operation, operands)
+		}
+	}
+	return c.calculate(operation, operands)
+}
+
+// Проверяет, является ли значение истинным, то есть не равным нулю. Вектор и число с единицей измерения
+// не могут быть условием
+func Truth(mode, value string) (bool, error) {
+	c, err := getCalculator(mode)
+	if err != nil {
+		return false, err
+	}
+	if hasUnit(value) {
+		return false, ErrDimensionalCondition
+	}
+	if isTensorLiteral(value) {
+		return false, ErrNotScalar
+	}
+	return c.truth(value)
+}
+
+// Проверяет, что значение - безразмерное число, а не вектор, матрица или величина с единицей измерения
+func IsScalar(value string) bool {
+	_, unit, err := rpn.SplitQuantity(value)
+	return err == nil && unit.Empty() && !isTensorLiteral(value)
+}
+
+// Возвращает вычислитель для режима
+func getCalculator(mode string) (calculator, error) {
+	c, ok := modes[normalize(mode)]
+	if !ok {
+		return nil, fmt.Errorf("Неизвестный режим вычислений: %s", mode)
+	}
+	return c, nil
+}
+
+// Подставляет режим по умолчанию вместо пустого
+func normalize(mode string) string {
+	if mode == "" {
+		return DefaultMode
+	}
+	return mode
+}
+
+// Вычислитель для режима со значениями типа T
+type typedCalculator[T any] struct {
+	arithmetic arithmetic[T]
+}
+
+// Проверяет, что литерал является корректным числом
+func (c typedCalculator[T]) parse(literal string) error {
+	_, err := c.arithmetic.Parse(literal)
+	return err
+}
+
+// Разбирает число и записывает его заново
+func (c typedCalculator[T]) normalize(literal string) (string, error) {
+	v, err := c.arithmetic.Parse(literal)
+	if err != nil {
+		return "", err
+	}
+	return c.arithmetic.Format(v), nil
+}
+
+// Проверяет, что значение не равно нулю
+func (c typedCalculator[T]) truth(value string) (bool, error) {
+	v, err := c.arithmetic.Parse(value)
+	if err != nil {
+		return false, err
+	}
+	return c.isTrue(v)
+}
+
+// Проверяет, что разобранное значение не равно нулю
+func (c typedCalculator[T]) isTrue(v T) (bool, error) {
+	zero, _ := c.arithmetic.Parse("0")
+	eq, err := c.equal(v, zero)
+	if err != nil {
+		return false, err
+	}
+	return !eq, nil
+}
+
+// Проверяет значения на равенство
+func (c typedCalculator[T]) equal(a, b T) (bool, error) {
+	if e, ok := c.arithmetic.(equality[T]); ok {
+		return e.Equal(a, b)
+	}
+	cmp, err := c.arithmetic.Cmp(a, b)
+	if err != nil {
+		return false, err
+	}
+	return cmp == 0, nil
+}
+
+// Вычисляет сопряженное число, действительную или мнимую часть. У действительных чисел сопряженное число
+// и действительная часть совпадают с самим числом, а мнимая часть равна нулю
+func (c typedCalculator[T]) part(operation string, a T) (T, error) {
+	parts, ok := c.arithmetic.(complexParts[T])
+	switch {
+	case ok && operation == "conj":
+		return parts.Conj(a)
+	case ok && operation == "re":
+		return parts.Re(a)
+	case ok:
+		return parts.Im(a)
+	case operation == "im":
+		return c.arithmetic.Parse("0")
+	}
+	return a, nil
+}
+
+// Переводит логическое значение в число 1 или 0
+func (c typedCalculator[T]) boolean(b bool) (T, error) {
+	if b {
+		return c.arithmetic.Parse("1")
+	}
+	return c.arithmetic.Parse("0")
+}
+
+// Разбирает операнды, вычисляет операцию и возвращает результат строкой
+func (c typedCalculator[T]) calculate(operation string, operands []string) (string, error) {
+	values := make([]T, len(operands))
+	for i, operand := range operands {
+		value, err := c.arithmetic.Parse(operand)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+
+	ar := c.arithmetic
+	unary := func(fn func(a T) (T, error)) (T, error) {
+		var res T
+		if err := checkArity(operation, values, 1, 1); err != nil {
+			return res, err
+		}
+		return fn(values[0])
+	}
+	binary := func(fn func(a, b T) (T, error)) (T, error) {
+		var res T
+		if err := checkArity(operation, values, 2, 2); err != nil {
+			return res, err
+		}
+		return fn(values[0], values[1])
+	}
+	compare := func(test func(cmp int) bool) (T, error) {
+		return binary(func(a, b T) (T, error) {
+			cmp, err := ar.Cmp(a, b)
+			if err != nil {
+				var res T
+				return res, err
+			}
+			return c.boolean(test(cmp))
+		})
+	}
+	equal := func(want bool) (T, error) {
+		return binary(func(a, b T) (T, error) {
+			eq, err := c.equal(a, b)
+			if err != nil {
+				var res T
+				return res, err
+			}
+			return c.boolean(eq == want)
+		})
+	}
+	logical := func(fn func(a, b bool) bool) (T, error) {
+		return binary(func(a, b T) (T, error) {
+			var res T
+			x, err := c.isTrue(a)
+			if err != nil {
+				return res, err
+			}
+			y, err := c.isTrue(b)
+			if err != nil {
+				return res, err
+			}
+			return c.boolean(fn(x, y))
+		})
+	}
+
+	var res T
+	var err error
+	switch operation {
+	case "neg":
+		res, err = unary(ar.Neg)
+	case "+":
+		res, err = binary(ar.Add)
+	case "-":
+		res, err = binary(ar.Sub)
+	case "*":
+		res, err = binary(ar.Mul)
+	case "/":
+		res, err = binary(ar.Div)
+	case "//":
+		res, err = binary(ar.IntDiv)
+	case "%":
+		res, err = binary(ar.Mod)
+	case "^":
+		res, err = binary(ar.Pow)
+	case "<":
+		res, err = compare(func(cmp int) bool { return cmp < 0 })
+	case "<=":
+		res, err = compare(func(cmp int) bool { return cmp <= 0 })
+	case "==":
+		res, err = equal(true)
+	case "!=":
+		res, err = equal(false)
+	case ">=":
+		res, err = compare(func(cmp int) bool { return cmp >= 0 })
+	case ">":
+		res, err = compare(func(cmp int) bool { return cmp > 0 })
+	case "and":
+		res, err = logical(func(a, b bool) bool { return a && b })
+	case "or":
+		res, err = logical(func(a, b bool) bool { return a || b })
+	case "not":
+		res, err = unary(func(a T) (T, error) {
+			b, err := c.isTrue(a)
+			if err != nil {
+				return a, err
+			}
+			return c.boolean(!b)
+		})
+	case "abs":
+		res, err = unary(ar.Abs)
+	case "sqrt":
+		res, err = unary(ar.Sqrt)
+	case "conj", "re", "im":
+		res, err = unary(func(a T) (T, error) {
+			return c.part(operation, a)
+		})
+	case "min", "max":
+		res, err = c.extremum(operation, values)
+	case "round":
+		if err = checkArity(operation, values, 1, 2); err != nil {
+			break
+		}
+		if len(values) == 1 {
+			digits, _ := ar.Parse("0")
+			values = append(values, digits)
+		}
+		res, err = ar.Round(values[0], values[1])
+	default:
+		err = fmt.Errorf("Неизвестная операция: %s", operation)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ar.Format(res), nil
+}
+
+// Находит минимальный или максимальный из операндов
+func (c typedCalculator[T]) extremum(operation string, values []T) (T, error) {
+	var res T
+	if err := checkArity(operation, values, 1, -1); err != nil {
+		return res, err
+	}
+	res = values[0]
+	for _, value := range values[1:] {
+		cmp, err := c.arithmetic.Cmp(value, res)
+		if err != nil {
+			return res, err
+		}
+		if (operation == "min" && cmp < 0) || (operation == "max" && cmp > 0) {
+			res = value
+		}
+	}
+	return res, nil
+}
+
+// Проверяет количество операндов операции. maxArity равен -1, если операндов может быть сколько угодно
+func checkArity[T any](operation string, values []T, minArity, maxArity int) error {
+	if len(values) < minArity || (maxArity != -1 && len(values) > maxArity) {
+		return fmt.Errorf(
+			"Неверное количество операндов операции %s: %d",
+			operation,
+			len(values),
+		)
+	}
+	return nil
+}
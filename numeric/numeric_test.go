@@ -0,0 +1,230 @@
+package numeric
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Ожидаемая ошибка вычисления, у которой нет отдельной переменной
+var errAny = errors.New("any error")
+
+// Вычисление и его ожидаемый результат. Если err не nil, результат не проверяется
+type calculation struct {
+	mode      string
+	operation string
+	operands  []string
+	want      string
+	err       error
+}
+
+func runCalculations(t *testing.T, tests []calculation) {
+	t.Helper()
+	for _, tt := range tests {
+		name := tt.mode + " " + tt.operation + " " + strings.Join(tt.operands, " ")
+		t.Run(name, func(t *testing.T) {
+			got, err := Calculate(tt.mode, tt.operation, tt.operands)
+			if tt.err != nil {
+				if err == nil || tt.err != errAny && !errors.Is(err, tt.err) {
+					t.Errorf("Calculate() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Calculate(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Calculate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateModes(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeInt64, "+", []string{"2", "3"}, "5", nil},
+		{ModeInt64, "/", []string{"7", "2"}, "3", nil},
+		{ModeInt64, "/", []string{"-7", "2"}, "-3", nil},
+		{ModeInt64, "neg", []string{"4"}, "-4", nil},
+		{"", "*", []string{"6", "7"}, "42", nil},
+		{ModeFloat64, "/", []string{"7", "2"}, "3.5", nil},
+		{ModeFloat64, "+", []string{"0.1", "0.2"}, "0.30000000000000004", nil},
+		{ModeFloat64, "*", []string{"1e308", "10"}, "+Inf", nil},
+		{ModeDecimal, "+", []string{"0.1", "0.2"}, "0.3", nil},
+		{ModeDecimal, "/", []string{"1", "3"}, "1/3", nil},
+		{ModeDecimal, "-", []string{"2.50", "0.25"}, "2.25", nil},
+		{ModeInt64, "/", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeFloat64, "/", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeDecimal, "/", []string{"1", "0"}, "", ErrDivisionByZero},
+	})
+}
+
+func TestModes(t *testing.T) {
+	for _, mode := range Modes() {
+		if !IsMode(mode) {
+			t.Errorf("IsMode(%s) = false", mode)
+		}
+	}
+	if !IsMode("") {
+		t.Error("IsMode(\"\") = false, want the default mode")
+	}
+	if IsMode("int32") {
+		t.Error("IsMode(int32) = true")
+	}
+	if _, err := Calculate("int32", "+", []string{"1", "2"}); err == nil {
+		t.Error("Calculate(int32) succeeded, want unknown mode error")
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		mode    string
+		literal string
+		valid   bool
+	}{
+		{ModeInt64, "42", true},
+		{ModeInt64, "1.5", false},
+		{ModeInt64, "99999999999999999999", false},
+		{ModeBigInt, "99999999999999999999", true},
+		{ModeFloat64, "1.5e3", true},
+		{ModeDecimal, "0.125", true},
+		{ModeFloat64, "4i", false},
+		{ModeComplex, "4i", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.literal, func(t *testing.T) {
+			err := ParseLiteral(tt.mode, tt.literal)
+			if (err == nil) != tt.valid {
+				t.Errorf("ParseLiteral() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCalculateOverflow(t *testing.T) {
+	const (
+		max = "9223372036854775807"
+		min = "-9223372036854775808"
+	)
+	runCalculations(t, []calculation{
+		{ModeInt64, "+", []string{max, "0"}, max, nil},
+		{ModeInt64, "+", []string{max, "1"}, "", ErrOverflow},
+		{ModeInt64, "-", []string{min, "1"}, "", ErrOverflow},
+		{ModeInt64, "-", []string{"0", min}, "", ErrOverflow},
+		{ModeInt64, "*", []string{"4294967296", "4294967296"}, "", ErrOverflow},
+		{ModeInt64, "*", []string{min, "-1"}, "", ErrOverflow},
+		{ModeInt64, "*", []string{"-1", min}, "", ErrOverflow},
+		{ModeInt64, "*", []string{min, "1"}, min, nil},
+		{ModeInt64, "/", []string{min, "-1"}, "", ErrOverflow},
+		{ModeInt64, "//", []string{min, "-1"}, "", ErrOverflow},
+		{ModeInt64, "neg", []string{min}, "", ErrOverflow},
+		{ModeInt64, "abs", []string{min}, "", ErrOverflow},
+		{ModeBigInt, "+", []string{max, "1"}, "9223372036854775808", nil},
+		{ModeBigInt, "*", []string{min, "-1"}, "9223372036854775808", nil},
+		{ModeBigInt, "/", []string{"-7", "2"}, "-3", nil},
+		{ModeBigInt, "/", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeBigInt, "%", []string{"1", "0"}, "", ErrDivisionByZero},
+	})
+}
+
+func TestCalculateFunctions(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeInt64, "^", []string{"2", "10"}, "1024", nil},
+		{ModeInt64, "^", []string{"2", "63"}, "", ErrOverflow},
+		{ModeInt64, "^", []string{"-2", "63"}, "-9223372036854775808", nil},
+		{ModeFloat64, "^", []string{"2", "-1"}, "0.5", nil},
+		{ModeInt64, "//", []string{"-7", "2"}, "-4", nil},
+		{ModeInt64, "%", []string{"-7", "2"}, "1", nil},
+		{ModeInt64, "%", []string{"7", "-2"}, "-1", nil},
+		{ModeFloat64, "//", []string{"-7", "2"}, "-4", nil},
+		{ModeFloat64, "%", []string{"-7", "2"}, "1", nil},
+		{ModeDecimal, "//", []string{"-7", "2"}, "-4", nil},
+		{ModeDecimal, "%", []string{"7.5", "2"}, "1.5", nil},
+		{ModeInt64, "//", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeInt64, "%", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeInt64, "abs", []string{"-3"}, "3", nil},
+		{ModeInt64, "sqrt", []string{"17"}, "4", nil},
+		{ModeFloat64, "sqrt", []string{"2.25"}, "1.5", nil},
+		{ModeFloat64, "sqrt", []string{"-1"}, "", ErrNegativeRoot},
+		{ModeInt64, "sqrt", []string{"-1"}, "", ErrNegativeRoot},
+		{ModeInt64, "min", []string{"3", "-1", "2"}, "-1", nil},
+		{ModeDecimal, "max", []string{"0.5", "1/3"}, "0.5", nil},
+		{ModeInt64, "round", []string{"1250", "-2"}, "1300", nil},
+		{ModeInt64, "round", []string{"-1250", "-2"}, "-1300", nil},
+		{ModeDecimal, "round", []string{"2.5"}, "3", nil},
+		{ModeDecimal, "round", []string{"1/3", "2"}, "0.33", nil},
+		{ModeFloat64, "round", []string{"-2.5"}, "-3", nil},
+	})
+}
+
+func TestCalculateLogic(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeInt64, "<", []string{"1", "2"}, "1", nil},
+		{ModeInt64, ">=", []string{"1", "2"}, "0", nil},
+		{ModeFloat64, "==", []string{"0.5", "0.50"}, "1", nil},
+		{ModeDecimal, "!=", []string{"1/2", "0.5"}, "0", nil},
+		{ModeDecimal, "<=", []string{"1/3", "0.33"}, "0", nil},
+		{ModeInt64, "and", []string{"2", "0"}, "0", nil},
+		{ModeInt64, "or", []string{"0", "-3"}, "1", nil},
+		{ModeFloat64, "not", []string{"0"}, "1", nil},
+		{ModeFloat64, "<", []string{"NaN", "1"}, "", ErrUndefined},
+		{ModeComplex, "==", []string{"1+2i", "1+2i"}, "1", nil},
+		{ModeComplex, "<", []string{"1+2i", "2"}, "", ErrUnordered},
+		{ModeComplex, "<", []string{"1", "2"}, "1", nil},
+	})
+}
+
+func TestTruth(t *testing.T) {
+	tests := []struct {
+		mode  string
+		value string
+		want  bool
+		err   error
+	}{
+		{ModeInt64, "0", false, nil},
+		{ModeInt64, "-1", true, nil},
+		{ModeDecimal, "1/3", true, nil},
+		{ModeFloat64, "0.0", false, nil},
+		{ModeComplex, "2i", true, nil},
+		{ModeDecimal, "5 m", false, ErrDimensionalCondition},
+		{ModeInt64, "[1, 0]", false, ErrNotScalar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.value, func(t *testing.T) {
+			got, err := Truth(tt.mode, tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Truth() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Truth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateComplex(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeComplex, "*", []string{"i", "i"}, "-1", nil},
+		{ModeComplex, "^", []string{"i", "2"}, "-1", nil},
+		{ModeComplex, "^", []string{"i", "-1"}, "-1i", nil},
+		{ModeComplex, "+", []string{"3+i", "-i"}, "3", nil},
+		{ModeComplex, "/", []string{"1", "2i"}, "-0.5i", nil},
+		{ModeComplex, "/", []string{"1", "0"}, "", ErrDivisionByZero},
+		{ModeComplex, "^", []string{"0", "-1"}, "", ErrDivisionByZero},
+		{ModeComplex, "sqrt", []string{"-4"}, "2i", nil},
+		{ModeComplex, "abs", []string{"3+4i"}, "5", nil},
+		{ModeComplex, "conj", []string{"3+4i"}, "3-4i", nil},
+		{ModeComplex, "re", []string{"3+4i"}, "3", nil},
+		{ModeComplex, "im", []string{"3+4i"}, "4", nil},
+		{ModeComplex, "round", []string{"1.26-2.24i", "1"}, "1.3-2.2i", nil},
+		{ModeComplex, "round", []string{"1", "1i"}, "", ErrDigits},
+		{ModeComplex, "//", []string{"4", "2"}, "", ErrNotReal},
+		{ModeComplex, "%", []string{"4", "2"}, "", ErrNotReal},
+		{ModeComplex, "max", []string{"1", "2+i"}, "", ErrUnordered},
+	})
+	for _, literal := range []string{"(1+2i)", "1_000", "2j"} {
+		if err := ParseLiteral(ModeComplex, literal); err == nil {
+			t.Errorf("ParseLiteral(%s, %q) succeeded, want error", ModeComplex, literal)
+		}
+	}
+}
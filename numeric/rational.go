@@ -0,0 +1,102 @@
+package numeric
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+// Ограничения, чтобы агент не ушел в бесконечные вычисления: размер результата возведения в степень в битах
+// и количество знаков округления
+const (
+	maxPowBits     = 1 << 20
+	maxRoundDigits = 1000
+)
+
+// Ошибки возведения в степень и округления
+var (
+	ErrPowTooLarge   = errors.New("Результат возведения в степень слишком большой")
+	ErrFractionalPow = errors.New("Дробная степень в точном режиме не поддерживается")
+	ErrDigits        = errors.New("Количество знаков округления должно быть целым числом от -1000 до 1000")
+)
+
+// Округляет рациональное число вниз до целого
+func floorRat(x *big.Rat) *big.Int {
+	// Евклидово деление при положительном знаменателе совпадает с округлением вниз
+	return new(big.Int).Div(x.Num(), x.Denom())
+}
+
+// Округляет рациональное число до digits знаков после запятой, половины округляются от нуля
+func roundRat(x *big.Rat, digits int64) *big.Rat {
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(abs64(digits)), nil))
+	if digits < 0 {
+		scale.Inv(scale)
+	}
+	scaled := new(big.Rat).Mul(x, scale)
+	half := big.NewRat(1, 2)
+	if scaled.Sign() < 0 {
+		half.Neg(half)
+	}
+	scaled.Add(scaled, half)
+	// Quo у big.Int отбрасывает дробную часть, то есть округляет к нулю
+	truncated := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	return new(big.Rat).Quo(new(big.Rat).SetInt(truncated), scale)
+}
+
+// Возводит рациональное число в целую степень. Размер результата сравнивается с ограничением делением,
+// а не умножением, чтобы проверка не переполнялась при огромной степени
+func powRat(x *big.Rat, n *big.Int) (*big.Rat, error) {
+	bits := int64(x.Num().BitLen() + x.Denom().BitLen())
+	if !n.IsInt64() || n.Int64() == math.MinInt64 || abs64(n.Int64()) > maxPowBits/bits {
+		if x.Num().BitLen() <= 1 && x.Denom().BitLen() <= 1 {
+			// 0, 1 и -1 в любой степени остаются маленькими
+			return powSmallRat(x, n)
+		}
+		return nil, ErrPowTooLarge
+	}
+	if n.Sign() < 0 && x.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	exponent := new(big.Int).Abs(n)
+	num := new(big.Int).Exp(x.Num(), exponent, nil)
+	denom := new(big.Int).Exp(x.Denom(), exponent, nil)
+	res := new(big.Rat).SetFrac(num, denom)
+	if n.Sign() < 0 {
+		res.Inv(res)
+	}
+	return res, nil
+}
+
+// Возводит 0, 1 или -1 в целую степень
+func powSmallRat(x *big.Rat, n *big.Int) (*big.Rat, error) {
+	switch {
+	case x.Sign() == 0 && n.Sign() < 0:
+		return nil, ErrDivisionByZero
+	case x.Sign() == 0 && n.Sign() > 0:
+		return new(big.Rat), nil
+	case x.Sign() < 0 && n.Bit(0) == 1:
+		return big.NewRat(-1, 1), nil
+	default:
+		return big.NewRat(1, 1), nil
+	}
+}
+
+// Переводит количество знаков округления в int64
+func digitsRat(digits *big.Rat) (int64, error) {
+	if !digits.IsInt() || !digits.Num().IsInt64() {
+		return 0, ErrDigits
+	}
+	n := digits.Num().Int64()
+	if n < -maxRoundDigits || n > maxRoundDigits {
+		return 0, ErrDigits
+	}
+	return n, nil
+}
+
+// Возвращает модуль числа. Модуль math.MinInt64 не помещается в int64, поэтому его нужно проверять отдельно
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
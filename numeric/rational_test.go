@@ -0,0 +1,69 @@
+package numeric
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPowLimits(t *testing.T) {
+	tests := []struct {
+		mode     string
+		operands []string
+		want     string
+		err      error
+	}{
+		{ModeBigInt, []string{"2", "10"}, "1024", nil},
+		{ModeDecimal, []string{"2", "-2"}, "0.25", nil},
+		{ModeBigInt, []string{"2", "4611686018427387904"}, "", ErrPowTooLarge},
+		{ModeDecimal, []string{"2", "4611686018427387904"}, "", ErrPowTooLarge},
+		{ModeDecimal, []string{"2", "-9223372036854775808"}, "", ErrPowTooLarge},
+		{ModeDecimal, []string{"1/3", "4611686018427387904"}, "", ErrPowTooLarge},
+		{ModeBigInt, []string{"2", "100000000000000000000"}, "", ErrPowTooLarge},
+		{ModeBigInt, []string{"1", "4611686018427387904"}, "1", nil},
+		{ModeBigInt, []string{"-1", "4611686018427387905"}, "-1", nil},
+		{ModeDecimal, []string{"-1", "-9223372036854775808"}, "1", nil},
+		{ModeDecimal, []string{"0", "-9223372036854775808"}, "", ErrDivisionByZero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.operands[0]+"^"+tt.operands[1], func(t *testing.T) {
+			got, err := Calculate(tt.mode, "^", tt.operands)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Calculate() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Calculate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundDigitsRange(t *testing.T) {
+	tests := []struct {
+		mode   string
+		digits string
+		want   string
+		err    error
+	}{
+		{ModeDecimal, "1", "1.5", nil},
+		{ModeDecimal, "1000", "1.5", nil},
+		{ModeDecimal, "1001", "", ErrDigits},
+		{ModeDecimal, "-9223372036854775808", "", ErrDigits},
+		{ModeBigInt, "-9223372036854775808", "", ErrDigits},
+		{ModeInt64, "-9223372036854775808", "", ErrDigits},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.digits, func(t *testing.T) {
+			value := "1.5"
+			if tt.mode != ModeDecimal {
+				value = "15"
+			}
+			got, err := Calculate(tt.mode, "round", []string{value, tt.digits})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Calculate() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && got != tt.want {
+				t.Errorf("Calculate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
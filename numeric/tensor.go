@@ -0,0 +1,453 @@
+package numeric
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ошибки операций над векторами и матрицами
+var (
+	ErrShape       = errors.New("Размеры векторов или матриц не совпадают")
+	ErrNotScalar   = errors.New("Ожидалось число, а не вектор")
+	ErrNotVector   = errors.New("Ожидался вектор")
+	ErrNotMatrix   = errors.New("Ожидалась матрица")
+	ErrRagged      = errors.New("Строки матрицы должны быть векторами одинаковой длины")
+	ErrTensorDepth = errors.New("Поддерживаются только векторы и матрицы")
+	ErrVectorMul   = errors.New("Векторы можно умножать только на число, для скалярного и матричного произведения используйте dot и matmul")
+)
+
+// Значение выражения: число или вектор. Матрица - вектор, элементы которого - векторы одинаковой длины
+type tensor struct {
+	scalar string
+	items  []tensor
+}
+
+// Проверяет, является ли значение вектором или матрицей
+func (t tensor) isVector() bool {
+	return t.items != nil
+}
+
+// Возвращает вложенность значения: 0 у числа, 1 у вектора, 2 у матрицы
+func (t tensor) depth() int {
+	if !t.isVector() {
+		return 0
+	}
+	return t.items[0].depth() + 1
+}
+
+// Записывает значение: "3", "[1, 2]", "[[1, 2], [3, 4]]"
+func (t tensor) String() string {
+	if !t.isVector() {
+		return t.scalar
+	}
+	items := make([]string, len(t.items))
+	for i, item := range t.items {
+		items[i] = item.String()
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// Проверяет, что значение записано вектором
+func isTensorLiteral(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "[")
+}
+
+// Разбирает значение. Числа не проверяются, это делает режим вычислений
+func parseTensor(s string) (tensor, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") {
+		return tensor{scalar: s}, nil
+	}
+	if !strings.HasSuffix(s, "]") {
+		return tensor{}, fmt.Errorf("Некорректный вектор: %s", s)
+	}
+	inner := s[1 : len(s)-1]
+	if strings.TrimSpace(inner) == "" {
+		return tensor{}, fmt.Errorf("Пустой вектор: %s", s)
+	}
+	var items []tensor
+	level, start := 0, 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			switch inner[i] {
+			case '[':
+				level++
+				continue
+			case ']':
+				level--
+				if level < 0 {
+					return tensor{}, fmt.Errorf("Некорректный вектор: %s", s)
+				}
+				continue
+			case ',':
+				if level > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		item := strings.TrimSpace(inner[start:i])
+		if item == "" {
+			return tensor{}, fmt.Errorf("Пустой элемент вектора: %s", s)
+		}
+		parsed, err := parseTensor(item)
+		if err != nil {
+			return tensor{}, err
+		}
+		items = append(items, parsed)
+		start = i + 1
+	}
+	if level != 0 {
+		return tensor{}, fmt.Errorf("Некорректный вектор: %s", s)
+	}
+	return newVector(items)
+}
+
+// Собирает вектор из элементов. Все элементы должны быть числами или векторами одной длины из чисел
+func newVector(items []tensor) (tensor, error) {
+	if len(items) == 0 {
+		return tensor{}, ErrShape
+	}
+	for _, item := range items {
+		switch {
+		case item.depth() > 1:
+			return tensor{}, ErrTensorDepth
+		case item.isVector() != items[0].isVector():
+			return tensor{}, ErrRagged
+		case item.isVector() && len(item.items) != len(items[0].items):
+			return tensor{}, ErrRagged
+		}
+	}
+	return tensor{items: items}, nil
+}
+
+// Операции, которые применяются к векторам поэлементно
+var elementwise = map[string]bool{
+	"neg":   true,
+	"+":     true,
+	"-":     true,
+	"*":     true,
+	"/":     true,
+	"//":    true,
+	"%":     true,
+	"^":     true,
+	"abs":   true,
+	"sqrt":  true,
+	"round": true,
+	"conj":  true,
+	"re":    true,
+	"im":    true,
+}
+
+// Операции, которые существуют только для векторов и матриц, и допустимое количество их операндов
+var tensorOperations = map[string][2]int{
+	"vector":    {1, -1},
+	"dot":       {2, 2},
+	"matmul":    {2, 2},
+	"transpose": {1, 1},
+	"sum":       {1, 1},
+}
+
+// Приводит каждое число значения к записи режима вычислений
+func (t tensor) normalize(c calculator) (tensor, error) {
+	if !t.isVector() {
+		scalar, err := c.normalize(t.scalar)
+		return tensor{scalar: scalar}, err
+	}
+	items := make([]tensor, len(t.items))
+	for i, item := range t.items {
+		normalized, err := item.normalize(c)
+		if err != nil {
+			return tensor{}, err
+		}
+		items[i] = normalized
+	}
+	return tensor{items: items}, nil
+}
+
+// Разбирает вектор или матрицу и записывает заново, приводя каждое число
+func normalizeTensor(c calculator, literal string) (string, error) {
+	value, err := parseTensor(literal)
+	if err != nil {
+		return "", err
+	}
+	value, err = value.normalize(c)
+	if err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// Вычисляет операцию, среди операндов которой есть векторы, или операцию над векторами.
+// Поэлементные операции применяются к каждому элементу, а число в паре с вектором применяется к каждому
+// его элементу: "[1, 2] * 3" = "[3, 6]"
+func calculateTensor(c calculator, operation string, operands []string) (string, error) {
+	values := make([]tensor, len(operands))
+	for i, operand := range operands {
+		value, err := parseTensor(operand)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	if arity, ok := tensorOperations[operation]; ok {
+		if err := checkArity(operation, values, arity[0], arity[1]); err != nil {
+			return "", err
+		}
+	}
+
+	var res tensor
+	var err error
+	switch {
+	case operation == "vector":
+		res, err = newVector(values)
+		if err == nil {
+			res, err = res.normalize(c)
+		}
+	case operation == "dot":
+		res, err = dot(c, values)
+	case operation == "matmul":
+		res, err = matmul(c, values)
+	case operation == "transpose":
+		res, err = transpose(values)
+		if err == nil {
+			res, err = res.normalize(c)
+		}
+	case operation == "sum":
+		res, err = sum(c, values)
+	case elementwise[operation]:
+		res, err = mapElements(c, operation, values)
+	default:
+		err = fmt.Errorf("Операция %s не определена для векторов", operation)
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// Применяет операцию к элементам с одинаковыми индексами. Числа среди операндов повторяются для каждого элемента,
+// количество знаков у round должно быть числом
+func mapElements(c calculator, operation string, values []tensor) (tensor, error) {
+	var shape *tensor
+	vectors := 0
+	for i, value := range values {
+		if !value.isVector() {
+			continue
+		}
+		if operation == "round" && i == 1 {
+			return tensor{}, ErrNotScalar
+		}
+		vectors++
+		if shape == nil {
+			shape = &values[i]
+		} else if len(value.items) != len(shape.items) || value.depth() != shape.depth() {
+			return tensor{}, ErrShape
+		}
+	}
+	if shape == nil {
+		res, err := c.calculate(operation, scalars(values))
+		return tensor{scalar: res}, err
+	}
+	if operation == "*" && vectors > 1 {
+		return tensor{}, ErrVectorMul
+	}
+
+	items := make([]tensor, len(shape.items))
+	for i := range items {
+		operands := make([]tensor, len(values))
+		for j, value := range values {
+			operands[j] = value
+			if value.isVector() {
+				operands[j] = value.items[i]
+			}
+		}
+		item, err := mapElements(c, operation, operands)
+		if err != nil {
+			return tensor{}, err
+		}
+		items[i] = item
+	}
+	return tensor{items: items}, nil
+}
+
+// Скалярное произведение двух векторов
+func dot(c calculator, values []tensor) (tensor, error) {
+	a, b := values[0], values[1]
+	if a.depth() != 1 || b.depth() != 1 {
+		return tensor{}, ErrNotVector
+	}
+	if len(a.items) != len(b.items) {
+		return tensor{}, ErrShape
+	}
+	res := "0"
+	for i := range a.items {
+		product, err := c.calculate("*", []string{a.items[i].scalar, b.items[i].scalar})
+		if err != nil {
+			return tensor{}, err
+		}
+		res, err = c.calculate("+", []string{res, product})
+		if err != nil {
+			return tensor{}, err
+		}
+	}
+	return tensor{scalar: res}, nil
+}
+
+// Матричное произведение. Вектор слева считается строкой, а вектор справа - столбцом,
+// и тогда результат - вектор
+func matmul(c calculator, values []tensor) (tensor, error) {
+	a, b := values[0], values[1]
+	if !a.isVector() || !b.isVector() {
+		return tensor{}, ErrNotMatrix
+	}
+	if a.depth() == 1 && b.depth() == 1 {
+		return tensor{}, ErrNotMatrix
+	}
+	rows := a.items
+	if a.depth() == 1 {
+		rows = []tensor{a}
+	}
+	columns := []tensor{b}
+	if b.depth() == 2 {
+		transposed, err := transpose([]tensor{b})
+		if err != nil {
+			return tensor{}, err
+		}
+		columns = transposed.items
+	}
+
+	items := make([]tensor, len(rows))
+	for i, row := range rows {
+		elements := make([]tensor, len(columns))
+		for j, column := range columns {
+			element, err := dot(c, []tensor{row, column})
+			if err != nil {
+				return tensor{}, err
+			}
+			elements[j] = element
+		}
+		items[i] = tensor{items: elements}
+		if b.depth() == 1 {
+			items[i] = elements[0]
+		}
+	}
+	if a.depth() == 1 {
+		return items[0], nil
+	}
+	return tensor{items: items}, nil
+}
+
+// Транспонирует матрицу
+func transpose(values []tensor) (tensor, error) {
+	a := values[0]
+	if a.depth() != 2 {
+		return tensor{}, ErrNotMatrix
+	}
+	items := make([]tensor, len(a.items[0].items))
+	for j := range items {
+		column := make([]tensor, len(a.items))
+		for i, row := range a.items {
+			column[i] = row.items[j]
+		}
+		items[j] = tensor{items: column}
+	}
+	return tensor{items: items}, nil
+}
+
+// Сумма всех элементов вектора или матрицы. Сумма числа - само число
+func sum(c calculator, values []tensor) (tensor, error) {
+	a := values[0]
+	if !a.isVector() {
+		return a, nil
+	}
+	res := "0"
+	for _, item := range a.items {
+		part, err := sum(c, []tensor{item})
+		if err != nil {
+			return tensor{}, err
+		}
+		res, err = c.calculate("+", []string{res, part.scalar})
+		if err != nil {
+			return tensor{}, err
+		}
+	}
+	return tensor{scalar: res}, nil
+}
+
+// Возвращает записи чисел
+func scalars(values []tensor) []string {
+	res := make([]string, len(values))
+	for i, value := range values {
+		res[i] = value.scalar
+	}
+	return res
+}
+
+// Разбивает поэлементную операцию над векторами на части, в каждой из которых у векторов не больше size
+// элементов (у матриц - строк). Части можно вычислить независимо, а результаты склеить функцией Concat.
+// Возвращает false, если операцию нельзя или незачем разбивать
+func Split(operation string, operands []string, size int) ([][]string, bool) {
+	if !elementwise[operation] || size <= 0 {
+		return nil, false
+	}
+	values := make([]tensor, len(operands))
+	length := -1
+	for i, operand := range operands {
+		if !isTensorLiteral(operand) {
+			values[i] = tensor{scalar: operand}
+			continue
+		}
+		value, err := parseTensor(operand)
+		if err != nil {
+			return nil, false
+		}
+		if length != -1 && len(value.items) != length {
+			return nil, false
+		}
+		values[i] = value
+		length = len(value.items)
+	}
+	if length <= size {
+		return nil, false
+	}
+
+	var parts [][]string
+	for start := 0; start < length; start += size {
+		end := start + size
+		if end > length {
+			end = length
+		}
+		part := make([]string, len(values))
+		for i, value := range values {
+			part[i] = value.scalar
+			if value.isVector() {
+				part[i] = tensor{items: value.items[start:end]}.String()
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts, true
+}
+
+// Склеивает векторы, вычисленные по частям функции Split, в один
+func Concat(parts []string) (string, error) {
+	var items []tensor
+	for _, part := range parts {
+		value, err := parseTensor(part)
+		if err != nil {
+			return "", err
+		}
+		if !value.isVector() {
+			return "", ErrNotVector
+		}
+		items = append(items, value.items...)
+	}
+	res, err := newVector(items)
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
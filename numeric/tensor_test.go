@@ -0,0 +1,63 @@
+package numeric
+
+import "testing"
+
+func TestCalculateTensor(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeInt64, "+", []string{"[1, 2]", "[3, 4]"}, "[4, 6]", nil},
+		{ModeInt64, "*", []string{"[1, 2]", "3"}, "[3, 6]", nil},
+		{ModeInt64, "-", []string{"10", "[1, 2]"}, "[9, 8]", nil},
+		{ModeInt64, "neg", []string{"[[1, -2], [3, 4]]"}, "[[-1, 2], [-3, -4]]", nil},
+		{ModeDecimal, "/", []string{"[1, 3]", "2"}, "[0.5, 1.5]", nil},
+		{ModeDecimal, "round", []string{"[1.25, 2.5]", "1"}, "[1.3, 2.5]", nil},
+		{ModeInt64, "round", []string{"1", "[1, 2]"}, "", ErrNotScalar},
+		{ModeInt64, "+", []string{"[1, 2]", "[1, 2, 3]"}, "", ErrShape},
+		{ModeInt64, "+", []string{"[1, 2]", "[[1, 2], [3, 4]]"}, "", ErrShape},
+		{ModeInt64, "*", []string{"[1, 2]", "[3, 4]"}, "", ErrVectorMul},
+		{ModeInt64, "+", []string{"[9223372036854775807]", "1"}, "", ErrOverflow},
+		{ModeInt64, "vector", []string{"1", "2", "3"}, "[1, 2, 3]", nil},
+		{ModeInt64, "vector", []string{"[1, 2]", "[3, 4]"}, "[[1, 2], [3, 4]]", nil},
+		{ModeInt64, "vector", []string{"[1, 2]", "3"}, "", ErrRagged},
+		{ModeInt64, "vector", []string{"[1, 2]", "[3]"}, "", ErrRagged},
+		{ModeInt64, "vector", []string{"[[1]]", "[[2]]"}, "", ErrTensorDepth},
+		{ModeInt64, "dot", []string{"[1, 2, 3]", "[4, 5, 6]"}, "32", nil},
+		{ModeInt64, "dot", []string{"[1, 2]", "[1]"}, "", ErrShape},
+		{ModeInt64, "dot", []string{"[[1]]", "[1]"}, "", ErrNotVector},
+		{ModeInt64, "matmul", []string{"[[1, 2], [3, 4]]", "[[5, 6], [7, 8]]"}, "[[19, 22], [43, 50]]", nil},
+		{ModeInt64, "matmul", []string{"[[1, 2], [3, 4]]", "[1, 1]"}, "[3, 7]", nil},
+		{ModeInt64, "matmul", []string{"[1, 2]", "[3, 4]"}, "", ErrNotMatrix},
+		{ModeInt64, "matmul", []string{"[[1, 2]]", "[[1, 2]]"}, "", ErrShape},
+		{ModeInt64, "transpose", []string{"[[1, 2, 3], [4, 5, 6]]"}, "[[1, 4], [2, 5], [3, 6]]", nil},
+		{ModeInt64, "sum", []string{"[[1, 2], [3, 4]]"}, "10", nil},
+	})
+	if _, err := Calculate(ModeInt64, "<", []string{"[1]", "[2]"}); err == nil {
+		t.Error("Calculate([1] < [2]) succeeded, want comparison of vectors to fail")
+	}
+}
+
+func TestNormalizeTensor(t *testing.T) {
+	tests := []struct {
+		mode    string
+		literal string
+		want    string
+		valid   bool
+	}{
+		{ModeDecimal, "[ 2.50 ,[1]]", "", false},
+		{ModeDecimal, "[[2.50, 1], [0.5, 3]]", "[[2.5, 1], [0.5, 3]]", true},
+		{ModeInt64, "[1, 2", "", false},
+		{ModeInt64, "[]", "", false},
+		{ModeInt64, "[1, , 2]", "", false},
+		{ModeInt64, "[1, 2.5]", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.literal, func(t *testing.T) {
+			got, err := Normalize(tt.mode, tt.literal)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Normalize() error = %v, want valid %v", err, tt.valid)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package numeric
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/oleg-top/go-orchestrator/rpn"
+)
+
+// Ошибки вычислений с единицами измерения
+var (
+	ErrDimensionalCondition = errors.New("Условием может быть только безразмерное число")
+	ErrDimensionalLogic     = errors.New("Логические операции определены только для безразмерных чисел")
+	ErrDimensionalExponent  = errors.New("Показатель степени должен быть безразмерным числом")
+	ErrUnitPower            = errors.New("Величину с единицей измерения можно возводить только в целую степень")
+)
+
+// Проверяет, записано ли значение с единицей измерения
+func hasUnit(value string) bool {
+	_, unit, err := rpn.SplitQuantity(value)
+	return err == nil && !unit.Empty()
+}
+
+// Вычисляет операцию над величинами с единицами измерения. Сначала по единицам операндов находится единица
+// результата, и числа переводятся в общие единицы, например при сложении "1 km + 300 m" оба числа
+// переводятся в метры. Затем над числами вычисляется сама операция. Несовместимые размерности (метры и секунды
+// при сложении) дают ошибку
+func calculateQuantity(c calculator, operation string, operands []string) (string, error) {
+	magnitudes := make([]string, len(operands))
+	units := make([]rpn.Unit, len(operands))
+	for i, operand := range operands {
+		magnitude, unit, err := rpn.SplitQuantity(operand)
+		if err != nil {
+			return "", err
+		}
+		magnitudes[i], units[i] = magnitude, unit
+	}
+	if err := checkArity(operation, operands, 1, -1); err != nil {
+		return "", err
+	}
+
+	// Переводит числа в новые единицы
+	convert := func(factors []*big.Rat) error {
+		for i, factor := range factors {
+			scaled, err := scale(c, magnitudes[i], factor)
+			if err != nil {
+				return err
+			}
+			magnitudes[i] = scaled
+		}
+		return nil
+	}
+
+	var unit rpn.Unit
+	switch operation {
+	case "+", "-", "%", "min", "max", rpn.Vector, "<", "<=", "==", "!=", ">=", ">", "//":
+		common, factors, err := rpn.Common(units)
+		if err != nil {
+			return "", err
+		}
+		if err := convert(factors); err != nil {
+			return "", err
+		}
+		switch operation {
+		case "+", "-", "%", "min", "max", rpn.Vector:
+			unit = common
+		}
+	case "*", "/", "dot", "matmul":
+		if err := checkArity(operation, operands, 2, 2); err != nil {
+			return "", err
+		}
+		aligned, factors := rpn.Align(units)
+		if err := convert(factors); err != nil {
+			return "", err
+		}
+		if operation == "/" {
+			aligned[1] = aligned[1].Pow(-1)
+		}
+		unit = aligned[0].Mul(aligned[1])
+	case "neg", "abs", "conj", "re", "im", "transpose", "sum":
+		unit = units[0]
+	case "round":
+		if len(units) > 1 && !units[1].Empty() {
+			return "", ErrDigits
+		}
+		unit = units[0]
+	case "sqrt":
+		root, ok := units[0].Root(2)
+		if !ok {
+			return "", fmt.Errorf("Корень из величины в %s не выражается в единицах измерения", units[0])
+		}
+		unit = root
+	case "^":
+		if err := checkArity(operation, operands, 2, 2); err != nil {
+			return "", err
+		}
+		if !units[1].Empty() {
+			return "", ErrDimensionalExponent
+		}
+		power, err := strconv.Atoi(magnitudes[1])
+		if err != nil {
+			return "", ErrUnitPower
+		}
+		unit = units[0].Pow(power)
+	case rpn.And, rpn.Or, rpn.Not:
+		return "", ErrDimensionalLogic
+	case rpn.To:
+		if err := checkArity(operation, operands, 2, 2); err != nil {
+			return "", err
+		}
+		factor, err := rpn.Conversion(units[0], units[1])
+		if err != nil {
+			return "", err
+		}
+		res, err := scale(c, magnitudes[0], factor)
+		if err != nil {
+			return "", err
+		}
+		return rpn.FormatQuantity(res, units[1]), nil
+	case rpn.SI:
+		if err := checkArity(operation, operands, 1, 1); err != nil {
+			return "", err
+		}
+		res, err := scale(c, magnitudes[0], units[0].Factor())
+		if err != nil {
+			return "", err
+		}
+		return rpn.FormatQuantity(res, units[0].SI()), nil
+	default:
+		return "", fmt.Errorf("Операция %s не определена для величин с единицами измерения", operation)
+	}
+
+	res, err := calculateValue(c, operation, magnitudes)
+	if err != nil {
+		return "", err
+	}
+	return rpn.FormatQuantity(res, unit), nil
+}
+
+// Умножает число на дробный множитель. Множитель раскладывается на целые числитель и знаменатель, чтобы перевод
+// работал в любом режиме: в int64 деление на знаменатель, как и обычное деление, отбрасывает дробную часть
+func scale(c calculator, magnitude string, factor *big.Rat) (string, error) {
+	var err error
+	if !factor.Num().IsInt64() || factor.Num().Int64() != 1 {
+		magnitude, err = calculateValue(c, "*", []string{magnitude, factor.Num().String()})
+		if err != nil {
+			return "", err
+		}
+	}
+	if !factor.IsInt() {
+		magnitude, err = calculateValue(c, "/", []string{magnitude, factor.Denom().String()})
+		if err != nil {
+			return "", err
+		}
+	}
+	return magnitude, nil
+}
+
+// Приводит число величины к записи режима и записывает единицу в каноническом виде
+func normalizeQuantity(c calculator, value string) (string, error) {
+	magnitude, unit, err := rpn.SplitQuantity(value)
+	if err != nil {
+		return "", err
+	}
+	if isTensorLiteral(magnitude) {
+		magnitude, err = normalizeTensor(c, magnitude)
+	} else {
+		magnitude, err = c.normalize(magnitude)
+	}
+	if err != nil {
+		return "", err
+	}
+	return rpn.FormatQuantity(magnitude, unit), nil
+}
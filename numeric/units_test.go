@@ -0,0 +1,51 @@
+package numeric
+
+import "testing"
+
+func TestCalculateQuantity(t *testing.T) {
+	runCalculations(t, []calculation{
+		{ModeDecimal, "+", []string{"1 km", "300 m"}, "1300 m", nil},
+		{ModeDecimal, "-", []string{"1 h", "30"}, "", errAny},
+		{ModeDecimal, "*", []string{"2 km", "3 m"}, "6000 m^2", nil},
+		{ModeDecimal, "/", []string{"100 km", "2 h"}, "50 km/h", nil},
+		{ModeDecimal, "/", []string{"6 m", "2 m"}, "3", nil},
+		{ModeDecimal, "*", []string{"2", "5 kg"}, "10 kg", nil},
+		{ModeDecimal, "^", []string{"3 m", "2"}, "9 m^2", nil},
+		{ModeDecimal, "^", []string{"3 m", "0.5"}, "", ErrUnitPower},
+		{ModeDecimal, "^", []string{"2", "3 m"}, "", ErrDimensionalExponent},
+		{ModeDecimal, "sqrt", []string{"9 m^2"}, "3 m", nil},
+		{ModeDecimal, "sqrt", []string{"9 m"}, "", errAny},
+		{ModeDecimal, "<", []string{"999 m", "1 km"}, "1", nil},
+		{ModeDecimal, "max", []string{"1 km", "999 m"}, "1000 m", nil},
+		{ModeDecimal, "+", []string{"1 m", "1 s"}, "", errAny},
+		{ModeDecimal, "and", []string{"1 m", "1"}, "", ErrDimensionalLogic},
+		{ModeDecimal, "round", []string{"1.26 m", "1"}, "1.3 m", nil},
+		{ModeDecimal, "round", []string{"1.26", "1 m"}, "", ErrDigits},
+		{ModeDecimal, "to", []string{"90 km/h", "1 m/s"}, "25 m/s", nil},
+		{ModeDecimal, "to", []string{"1 m", "1 s"}, "", errAny},
+		{ModeDecimal, "si", []string{"2 km"}, "2000 m", nil},
+		{ModeDecimal, "si", []string{"3 N"}, "3 m*kg/s^2", nil},
+		{ModeInt64, "to", []string{"1500 m", "1 km"}, "1 km", nil},
+		{ModeInt64, "*", []string{"[1, 2] m", "3"}, "[3, 6] m", nil},
+		{ModeFloat64, "+", []string{"1.5 m", "50 cm"}, "200 cm", nil},
+	})
+}
+
+func TestIsScalar(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"5", true},
+		{"-2.5e3", true},
+		{"3+4i", true},
+		{"5 m", false},
+		{"[1, 2]", false},
+		{"5 furlong", false},
+	}
+	for _, tt := range tests {
+		if got := IsScalar(tt.value); got != tt.want {
+			t.Errorf("IsScalar(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
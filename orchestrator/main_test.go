@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/oleg-top/go-orchestrator/broker"
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+)
+
+// Создает оркестратор с пустой бд в памяти
+func newTestOrchestrator(t *testing.T) *Orchestrator {
+	t.Helper()
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// У каждого соединения с :memory: своя бд
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	db.MustExec(schema)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	// Соединение без брокера: отправка операций возвращает ErrDisconnected, и они остаются в outbox
+	o := NewOrchestrator(db, &broker.Connection{})
+	o.Timeouts = map[string]time.Duration{}
+	o.ChunkSize = defaultChunkSize
+	return o
+}
+
+func TestValidateExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		mode       string
+		variables  storage.Variables
+		code       string
+	}{
+		{"x * 2 + y", numeric.ModeInt64, storage.Variables{"x": "3", "y": "-1"}, ""},
+		{"x * 2 + y", numeric.ModeInt64, storage.Variables{"x": "3"}, rpn.ErrUnboundVariable},
+		{"x * 2", numeric.ModeInt64, storage.Variables{"x": "1.5"}, rpn.ErrInvalidBinding},
+		{"x * 2", numeric.ModeDecimal, storage.Variables{"x": "1.5"}, ""},
+		{"x * 2.5", numeric.ModeInt64, storage.Variables{"x": "1"}, rpn.ErrInvalidNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.expression, func(t *testing.T) {
+			r, err := rpn.NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			err = validateExpression(r.Tree, tt.mode, tt.variables)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("validateExpression() error = %v, want nil", err)
+				}
+				return
+			}
+			parseErr, ok := err.(*rpn.ParseError)
+			if !ok || parseErr.Code != tt.code {
+				t.Errorf("validateExpression() error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+// Повторный запуск разбирает выражение с функциями, сохраненными в задаче, а не с текущим реестром
+func TestRunExpressionStoredFunctions(t *testing.T) {
+	o := newTestOrchestrator(t)
+	id, err := o.Storage.AddTask(storage.Task{
+		Expression:   "double(x)",
+		Mode:         numeric.ModeInt64,
+		Variables:    storage.Variables{"x": "1"},
+		Functions:    storage.Functions{"double(x) = x * 2"},
+		Optimized:    "x * 2",
+		CriticalPath: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := o.Storage.GetTaskById(id)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetTaskById() = %v, %v", tasks, err)
+	}
+	if want := (storage.Functions{"double(x) = x * 2"}); !reflect.DeepEqual(tasks[0].Functions, want) {
+		t.Errorf("Functions = %q, want %q", tasks[0].Functions, want)
+	}
+
+	// В реестре функции double уже нет. Некорректное значение переменной останавливает запуск до отправки агенту
+	recorder := httptest.NewRecorder()
+	o.Router.ServeHTTP(recorder, httptest.NewRequest("POST", "/expressions/"+id.String()+"/runs",
+		strings.NewReader(`{"variables": [{"x": 1.5}]}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusBadRequest, recorder.Body.String())
+	}
+	var response struct {
+		Code  string `json:"code"`
+		Index int    `json:"index"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != rpn.ErrInvalidBinding {
+		t.Errorf("code = %q, want %q", response.Code, rpn.ErrInvalidBinding)
+	}
+}
+
+func TestVariableValueJSON(t *testing.T) {
+	tests := []struct {
+		json  string
+		want  string
+		valid bool
+	}{
+		{`3`, "3", true},
+		{`-2.50`, "-2.50", true},
+		{`1e3`, "1e3", true},
+		{`"5 m"`, "5 m", true},
+		{`"3+4i"`, "3+4i", true},
+		{`[1, 2]`, "[1, 2]", true},
+		{`[[1, 2], [3, 4]]`, "[[1, 2], [3, 4]]", true},
+		{`true`, "", false},
+		{`{"x": 1}`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var v variableValue
+			err := json.Unmarshal([]byte(tt.json), &v)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Unmarshal() error = %v, want valid %v", err, tt.valid)
+			}
+			if string(v) != tt.want {
+				t.Errorf("Unmarshal() = %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExpressionImaginaryUnit(t *testing.T) {
+	tests := []struct {
+		expression string
+		mode       string
+		variables  storage.Variables
+		valid      bool
+	}{
+		{"i * 2", numeric.ModeInt64, storage.Variables{"i": "3"}, true},
+		{"i * 2", numeric.ModeInt64, nil, false},
+		{"i * 2", numeric.ModeComplex, nil, true},
+		{"i * 2", numeric.ModeComplex, storage.Variables{"i": "3"}, true},
+		{"i = 2; i * 3", numeric.ModeInt64, nil, true},
+		{"4i + 1", numeric.ModeInt64, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.expression, func(t *testing.T) {
+			r, err := rpn.NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			err = validateExpression(r.Tree, tt.mode, tt.variables)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateExpression() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestLookupVariable(t *testing.T) {
+	tests := []struct {
+		mode      string
+		variables map[string]string
+		name      string
+		value     string
+		ok        bool
+	}{
+		{numeric.ModeInt64, map[string]string{"i": "3"}, "i", "3", true},
+		{numeric.ModeComplex, map[string]string{"i": "3"}, "i", "3", true},
+		{numeric.ModeComplex, nil, "i", rpn.ImaginaryUnit, true},
+		{numeric.ModeFloat64, nil, "i", "", false},
+		{numeric.ModeComplex, nil, "x", "", false},
+	}
+	for _, tt := range tests {
+		value, ok := lookupVariable(tt.mode, tt.variables, tt.name)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("lookupVariable(%s, %v, %s) = %q, %v, want %q, %v",
+				tt.mode, tt.variables, tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+}
+
+// SetTimeouts заменяет карту целиком, поэтому ее можно читать, пока она меняется
+func TestSetTimeoutsConcurrentReads(t *testing.T) {
+	o := &Orchestrator{Timeouts: map[string]time.Duration{"add": time.Second}}
+	r, err := rpn.NewRPN("1 + 2 + 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := o.currentTimeouts()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				request := httptest.NewRequest("POST", "/timeouts", strings.NewReader(`{"add": 5, "mul": 7}`))
+				o.SetTimeouts(httptest.NewRecorder(), request)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rpn.EstimateDuration(r.Tree, o.currentTimeouts())
+				_ = before["add"]
+			}
+		}()
+	}
+	wg.Wait()
+
+	if before["add"] != time.Second {
+		t.Errorf("old timeouts changed: add = %s, want 1s", before["add"])
+	}
+	if got := o.currentTimeouts()["add"]; got != 5*time.Millisecond {
+		t.Errorf("add = %s, want 5ms", got)
+	}
+}
+
+// Задача начинает вычисляться до ответа клиенту. Пока брокер недоступен, операции ждут в outbox,
+// а клиент все равно получает id задачи
+func TestAddExpressionSchedulesBeforeResponse(t *testing.T) {
+	o := newTestOrchestrator(t)
+	request := httptest.NewRequest("POST", "/expressions", strings.NewReader(`{"expression": "2 * x + 3 * y", "variables": {"x": 1, "y": 2}}`))
+	recorder := httptest.NewRecorder()
+	o.Router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	id, err := uuid.Parse(response.ID)
+	if err != nil {
+		t.Fatalf("id = %q: %v", response.ID, err)
+	}
+	o.mu.Lock()
+	e, ok := o.executions[id]
+	o.mu.Unlock()
+	if !ok {
+		t.Fatal("task is not scheduled after the response")
+	}
+	if len(e.outbox) != 2 {
+		t.Errorf("len(outbox) = %d, want 2 operations waiting for the broker", len(e.outbox))
+	}
+}
+
+// Выражение запускается заново с новыми значениями переменных, и каждый набор оптимизируется отдельно
+func TestRunExpression(t *testing.T) {
+	o := newTestOrchestrator(t)
+	recorder := httptest.NewRecorder()
+	o.Router.ServeHTTP(recorder, httptest.NewRequest("POST", "/expressions",
+		strings.NewReader(`{"expression": "x * 0 + y", "variables": {"x": 1, "y": 2}}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("POST /expressions status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		code      int
+		optimized []string
+	}{
+		{"scalar and vector", `{"variables": [{"x": 5, "y": 1}, {"x": [1, 2], "y": 1}]}`, http.StatusOK, []string{"y", "x * 0 + y"}},
+		{"missing variable", `{"variables": [{"x": 5, "y": 1}, {"x": 1}]}`, http.StatusBadRequest, nil},
+		{"invalid value", `{"variables": [{"x": 1.5, "y": 1}]}`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			o.Router.ServeHTTP(recorder, httptest.NewRequest("POST", "/expressions/"+created.ID+"/runs", strings.NewReader(tt.body)))
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.code, recorder.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var response struct {
+				IDs []uuid.UUID `json:"ids"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.IDs) != len(tt.optimized) {
+				t.Fatalf("ids = %v, want %d runs", response.IDs, len(tt.optimized))
+			}
+			for i, id := range response.IDs {
+				tasks, err := o.Storage.GetTaskById(id)
+				if err != nil || len(tasks) != 1 {
+					t.Fatalf("GetTaskById() = %v, %v", tasks, err)
+				}
+				if tasks[0].Optimized != tt.optimized[i] {
+					t.Errorf("run %d optimized = %q, want %q", i, tasks[0].Optimized, tt.optimized[i])
+				}
+				// Выражение без операций вычисляется сразу при запуске
+				if _, ok := o.executions[id]; !ok && tasks[0].Status != storage.StatusTaskCompleted {
+					t.Errorf("run %d is neither scheduled nor completed: %s", i, tasks[0].Status)
+				}
+			}
+		})
+	}
+}
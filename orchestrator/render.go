@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+)
+
+// Форматы, в которых можно получить выражение
+const (
+	formatInfix = "infix"
+	formatRPN   = "rpn"
+	formatLatex = "latex"
+	formatTree  = "tree"
+)
+
+var renderFormats = []string{formatInfix, formatRPN, formatLatex, formatTree}
+
+// Какое выражение записывать: то, что вычислял агент после оптимизации, или то, что прислал пользователь
+const (
+	sourceOptimized = "optimized"
+	sourceOriginal  = "original"
+)
+
+// Узел дерева выражения в json. На общий узел ссылаются несколько операций: целиком он записывается
+// только в первый раз, а дальше - ссылкой с тем же id и без операндов. У вычисленного узла есть результат
+// или ошибка, а также время начала и конца его операции в миллисекундах от начала вычисления
+type treeNode struct {
+	ID        int         `json:"id"`
+	Kind      string      `json:"kind"`
+	Value     string      `json:"value"`
+	Ref       bool        `json:"ref,omitempty"`
+	Evaluated bool        `json:"evaluated"`
+	Result    string      `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	StartMs   int64       `json:"start_ms"`
+	EndMs     int64       `json:"end_ms"`
+	Children  []*treeNode `json:"children,omitempty"`
+}
+
+// Получение выражения в заданном формате: обычной записи, обратной польской нотации, LaTeX
+// или дереве с результатами и временем каждого узла
+func (o *Orchestrator) RenderExpression(w http.ResponseWriter, r *http.Request) {
+	validID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error while parsing id: " + err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatInfix
+	}
+	if !isRenderFormat(format) {
+		writeJSONError(w, http.StatusBadRequest, map[string]any{
+			"code":    "unknown_format",
+			"message": "Неизвестный формат: " + format,
+			"formats": renderFormats,
+		})
+		return
+	}
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = sourceOptimized
+	}
+	if source != sourceOptimized && source != sourceOriginal {
+		writeJSONError(w, http.StatusBadRequest, map[string]any{
+			"code":    "unknown_source",
+			"message": "Неизвестный источник: " + source,
+			"sources": []string{sourceOptimized, sourceOriginal},
+		})
+		return
+	}
+
+	tasks, err := o.Storage.GetTaskById(validID)
+	if err != nil {
+		log.Error("Error while getting expression by id: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(tasks) == 0 {
+		http.Error(w, "Expression not found", http.StatusNotFound)
+		return
+	}
+	task := tasks[0]
+	parsed, err := o.parseTask(task, source)
+	if err != nil {
+		log.Error("Error while parsing expression: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{"id": task.ID, "format": format, "source": source}
+	switch format {
+	case formatInfix:
+		response["expression"] = rpn.Infix(parsed.Tree)
+	case formatRPN:
+		response["expression"] = parsed.RPNExpression
+	case formatLatex:
+		response["expression"] = rpn.Latex(parsed.Tree)
+	case formatTree:
+		var trace []storage.TraceEntry
+		// Трассировка записана по узлам программы, которую вычислял агент, а у исходного выражения другие узлы
+		if source == sourceOptimized && task.Optimized != "" {
+			trace, err = o.Storage.GetTrace(task.ID)
+			if err != nil {
+				log.Error("Error while getting trace: " + err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		response["tree"] = annotateTree(parsed.Tree, task, trace)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Error while encoding json: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Проверяет, поддерживается ли формат записи выражения
+func isRenderFormat(format string) bool {
+	for _, f := range renderFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Разбирает выражение задачи. Оптимизированная программа записана в обычной нотации, а вызовы
+// пользовательских функций в ней уже заменены их телами. У старых задач оптимизированной программы нет,
+// и тогда разбирается исходное выражение с функциями, сохраненными вместе с задачей
+func (o *Orchestrator) parseTask(task storage.Task, source string) (*rpn.RPN, error) {
+	if source == sourceOptimized && task.Optimized != "" {
+		return rpn.Parse(task.Optimized, rpn.Options{})
+	}
+	definitions, err := rpn.ParseDefinitions(task.Functions)
+	if err != nil {
+		return nil, err
+	}
+	return rpn.Parse(task.Expression, rpn.Options{Functions: definitions, Notation: task.Notation})
+}
+
+// Строит дерево выражения в json. Результаты и время операций берутся из трассировки агентов, а у чисел
+// и переменных результат - операнд, с которым их взяла операция. Результат if - значение выбранной ветки.
+// Чего нет в трассировке, например выражения из одного числа, то в корне берется из результата задачи.
+// Пока задача не завершена, дерево не заполняется
+func annotateTree(tree *rpn.Node, task storage.Task, trace []storage.TraceEntry) *treeNode {
+	ids := tree.IDs()
+	views := make(map[*rpn.Node]*treeNode)
+	var refs []*treeNode
+	var build func(node *rpn.Node) *treeNode
+	build = func(node *rpn.Node) *treeNode {
+		result := &treeNode{ID: ids[node], Kind: node.Kind.String(), Value: node.Value}
+		if _, ok := views[node]; ok {
+			result.Ref = true
+			refs = append(refs, result)
+			return result
+		}
+		views[node] = result
+		for _, child := range node.Children {
+			result.Children = append(result.Children, build(child))
+		}
+		return result
+	}
+	root := build(tree)
+	if task.Status != storage.StatusTaskCompleted && task.Status != storage.StatusTaskInvalid {
+		return root
+	}
+
+	traced := traceByNode(trace)
+	filled := make(map[*rpn.Node]bool)
+	var fill func(node *rpn.Node)
+	fill = func(node *rpn.Node) {
+		if filled[node] {
+			return
+		}
+		filled[node] = true
+		for _, child := range node.Children {
+			fill(child)
+		}
+		entry, ok := traced[ids[node]]
+		if !ok {
+			return
+		}
+		view := views[node]
+		view.Evaluated = true
+		view.StartMs, view.EndMs = entry.start, entry.end
+		for i, operand := range entry.operands {
+			if child := node.Children[i]; child.Kind == rpn.NodeNumber || child.Kind == rpn.NodeVariable {
+				leaf := views[child]
+				leaf.Evaluated, leaf.Result = true, operand
+			}
+		}
+		if node.Kind != rpn.NodeFunction || node.Value != rpn.If || entry.err != "" {
+			view.Result, view.Error = entry.result, entry.err
+			return
+		}
+		// У if в трассировке только проверка условия, а значение готово, когда вычислена выбранная ветка
+		branch := views[node.Children[2]]
+		if entry.result == "1" {
+			branch = views[node.Children[1]]
+		}
+		if branch.Evaluated {
+			view.Result, view.Error = branch.Result, branch.Error
+			if branch.EndMs > view.EndMs {
+				view.EndMs = branch.EndMs
+			}
+		}
+	}
+	fill(tree)
+	if !root.Evaluated || (root.Result == "" && root.Error == "") {
+		root.Evaluated = true
+		root.Result, root.Error = task.Result, task.Error
+	}
+	nodes := make(map[int]*treeNode, len(views))
+	for _, view := range views {
+		nodes[view.ID] = view
+	}
+	for _, ref := range refs {
+		view := nodes[ref.ID]
+		ref.Evaluated, ref.Result, ref.Error = view.Evaluated, view.Result, view.Error
+		ref.StartMs, ref.EndMs = view.StartMs, view.EndMs
+	}
+	return root
+}
+
+// Время операции из трассировки в миллисекундах от начала первой операции, ее операнды, результат и ошибка
+type tracedOperation struct {
+	start, end  int64
+	operands    []string
+	result, err string
+}
+
+// Группирует трассировку по номерам узлов. Части операции, которая считалась по частям, объединяются:
+// операция идет от начала первой части до конца последней, а ее результат - склеенные результаты частей.
+// Операнды у такой операции не записываются: в каждой части только ее срез операндов
+func traceByNode(trace []storage.TraceEntry) map[int]tracedOperation {
+	operations := make(map[int]tracedOperation, len(trace))
+	chunks := make(map[int][]traceEntryView)
+	for _, view := range newTraceViews(trace) {
+		if view.Chunk != 0 {
+			chunks[view.Node] = append(chunks[view.Node], view)
+			continue
+		}
+		operations[view.Node] = tracedOperation{
+			start:    view.StartMs,
+			end:      view.EndMs,
+			operands: view.Operands,
+			result:   view.Result,
+			err:      view.Error,
+		}
+	}
+	for node, views := range chunks {
+		sort.Slice(views, func(i, j int) bool {
+			return views[i].Chunk < views[j].Chunk
+		})
+		operation := tracedOperation{start: views[0].StartMs, end: views[0].EndMs}
+		results := make([]string, len(views))
+		for i, view := range views {
+			if view.StartMs < operation.start {
+				operation.start = view.StartMs
+			}
+			if view.EndMs > operation.end {
+				operation.end = view.EndMs
+			}
+			if view.Error != "" && operation.err == "" {
+				operation.err = view.Error
+			}
+			results[i] = view.Result
+		}
+		if operation.err == "" {
+			operation.result, _ = numeric.Concat(results)
+		}
+		operations[node] = operation
+	}
+	return operations
+}
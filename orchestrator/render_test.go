@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/rpn"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+func TestParseTaskDefinitions(t *testing.T) {
+	o := newTestOrchestrator(t)
+	// Функцию изменили после отправки задачи: разбирается определение, сохраненное вместе с задачей
+	if err := o.Storage.SaveFunction("double", "double(x) = x * 3"); err != nil {
+		t.Fatal(err)
+	}
+	double := storage.Functions{"double(x) = x * 2"}
+
+	tests := []struct {
+		name   string
+		task   storage.Task
+		source string
+		infix  string
+		valid  bool
+	}{
+		{"unrelated", storage.Task{Expression: "1 + 2"}, sourceOriginal, "1 + 2", true},
+		{"stored", storage.Task{Expression: "double(3)", Functions: double}, sourceOriginal, "3 * 2", true},
+		{"not stored", storage.Task{Expression: "double(3)"}, sourceOriginal, "", false},
+		{"optimized", storage.Task{Expression: "double(3)", Optimized: "3 * 2"}, sourceOptimized, "3 * 2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := o.parseTask(tt.task, tt.source)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parseTask() error = %v, want valid %v", err, tt.valid)
+			}
+			if err == nil && rpn.Infix(parsed.Tree) != tt.infix {
+				t.Errorf("parseTask() = %q, want %q", rpn.Infix(parsed.Tree), tt.infix)
+			}
+		})
+	}
+}
+
+func TestAnnotateTree(t *testing.T) {
+	parsed, err := rpn.Parse("if(x > 1, x * 2, 0)", rpn.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := parsed.Tree.IDs()
+	condition, then := parsed.Tree.Children[0], parsed.Tree.Children[1]
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	trace := toTraceEntries([]serialization.TraceEntry{
+		{Node: ids[condition], Operation: ">", Operands: []string{"3", "1"}, Result: "1", Start: at(0), End: at(100)},
+		{Node: ids[parsed.Tree], Operation: rpn.If, Operands: []string{"1"}, Result: "1", Start: at(100), End: at(150)},
+		{Node: ids[then], Operation: "*", Operands: []string{"3", "2"}, Result: "6", Start: at(150), End: at(250)},
+	})
+
+	root := annotateTree(parsed.Tree, storage.Task{Status: storage.StatusTaskCalculating}, trace)
+	if root.Evaluated || root.Children[0].Evaluated {
+		t.Errorf("unfinished tree = %+v, want not evaluated", root)
+	}
+
+	root = annotateTree(parsed.Tree, storage.Task{Status: storage.StatusTaskCompleted, Result: "6"}, trace)
+	if !root.Evaluated || root.Result != "6" || root.StartMs != 100 || root.EndMs != 250 {
+		t.Errorf("root = %+v, want 6 from 100 to 250 ms", root)
+	}
+	if x := root.Children[1].Children[0]; !x.Evaluated || x.Result != "3" {
+		t.Errorf("x = %+v, want operand 3", x)
+	}
+	// Ветка, которую не выбрало условие, не вычисляется
+	if otherwise := root.Children[2]; otherwise.Evaluated {
+		t.Errorf("else branch = %+v, want not evaluated", otherwise)
+	}
+
+	// В выражении из одного числа нет операций, и результат берется из задачи
+	parsed, err = rpn.Parse("5", rpn.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = annotateTree(parsed.Tree, storage.Task{Status: storage.StatusTaskCompleted, Result: "5"}, nil)
+	if !root.Evaluated || root.Result != "5" {
+		t.Errorf("number root = %+v, want result 5", root)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/oleg-top/go-orchestrator/broker"
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+// Операция выражения, отправленная агентам: номер узла и номер части (0, если операция не делилась на части)
+type operationKey struct {
+	node  int
+	chunk int
+}
+
+// Состояние распределенного вычисления одной задачи. Оркестратор отправляет в очередь операции, операнды
+// которых уже вычислены, а по мере прихода результатов - зависящие от них операции, поэтому операции одного
+// выражения считают все свободные агенты. Каждый узел вычисляется один раз, у if сначала проверяется условие,
+// а затем вычисляется только выбранная ветка. Поэлементные операции над векторами длиннее chunkSize
+// отправляются по частям, и части тоже могут считать разные агенты
+type execution struct {
+	task      storage.Task
+	tree      *rpn.Node
+	ids       map[*rpn.Node]int
+	nodes     map[int]*rpn.Node
+	parents   map[*rpn.Node][]*rpn.Node
+	timeouts  map[string]time.Duration
+	chunkSize int
+
+	requested map[*rpn.Node]bool
+	published map[*rpn.Node]bool
+	values    map[*rpn.Node]string
+	branches  map[*rpn.Node]int
+	chunks    map[*rpn.Node][]string
+	waiting   map[*rpn.Node]int
+	started   map[*rpn.Node]time.Time
+	// Отправленные операции, результат которых еще не пришел, и агенты, которые их считают
+	pending     map[operationKey]serialization.OperationMessage
+	agents      map[operationKey]uuid.UUID
+	calculating bool
+	completed   int
+	trace       []serialization.TraceEntry
+	// Операции, которые готовы к отправке в очередь
+	outbox []serialization.OperationMessage
+	done   bool
+	result string
+	err    error
+}
+
+// Функция, создающая состояние вычисления задачи по дереву ее выражения
+func newExecution(
+	task storage.Task,
+	tree *rpn.Node,
+	timeouts map[string]time.Duration,
+	chunkSize int,
+) *execution {
+	e := &execution{
+		task:      task,
+		tree:      tree,
+		ids:       tree.IDs(),
+		nodes:     make(map[int]*rpn.Node),
+		parents:   make(map[*rpn.Node][]*rpn.Node),
+		timeouts:  timeouts,
+		chunkSize: chunkSize,
+		requested: make(map[*rpn.Node]bool),
+		published: make(map[*rpn.Node]bool),
+		values:    make(map[*rpn.Node]string),
+		branches:  make(map[*rpn.Node]int),
+		chunks:    make(map[*rpn.Node][]string),
+		waiting:   make(map[*rpn.Node]int),
+		started:   make(map[*rpn.Node]time.Time),
+		pending:   make(map[operationKey]serialization.OperationMessage),
+		agents:    make(map[operationKey]uuid.UUID),
+	}
+	for node, id := range e.ids {
+		e.nodes[id] = node
+		for _, child := range node.Children {
+			e.parents[child] = append(e.parents[child], node)
+		}
+	}
+	return e
+}
+
+// Функция, которая начинает вычисление с корня дерева
+func (e *execution) start() {
+	e.request(e.tree)
+}
+
+// Функция, которая отмечает узел нужным для результата. Числа и переменные вычисляются сразу,
+// а операция отправляется, как только готовы ее операнды
+func (e *execution) request(node *rpn.Node) {
+	if e.done || e.requested[node] {
+		return
+	}
+	e.requested[node] = true
+	switch node.Kind {
+	case rpn.NodeNumber:
+		value, err := numeric.Normalize(e.task.Mode, node.Value)
+		if err != nil {
+			e.fail(err)
+			return
+		}
+		e.resolve(node, value)
+		return
+	case rpn.NodeVariable:
+		value, ok := lookupVariable(e.task.Mode, e.task.Variables, node.Value)
+		if !ok {
+			e.fail(fmt.Errorf("Не задано значение переменной %s", node.Value))
+			return
+		}
+		value, err := numeric.Normalize(e.task.Mode, value)
+		if err != nil {
+			e.fail(fmt.Errorf("Некорректное значение переменной %s: %w", node.Value, err))
+			return
+		}
+		e.resolve(node, value)
+		return
+	}
+	if node.Kind == rpn.NodeFunction && node.Value == rpn.If {
+		e.request(node.Children[0])
+	} else {
+		for _, child := range node.Children {
+			e.request(child)
+		}
+	}
+	e.publish(node)
+}
+
+// Функция, которая отправляет операцию узла, если готовы все ее операнды (у if - условие)
+func (e *execution) publish(node *rpn.Node) {
+	if e.done || !e.requested[node] || e.published[node] {
+		return
+	}
+	children := node.Children
+	if node.Kind == rpn.NodeFunction && node.Value == rpn.If {
+		children = children[:1]
+	}
+	operands := make([]string, len(children))
+	for i, child := range children {
+		value, ok := e.values[child]
+		if !ok {
+			return
+		}
+		operands[i] = value
+	}
+
+	e.published[node] = true
+	parts := [][]string{operands}
+	chunked := false
+	if chunks, ok := numeric.Split(node.Value, operands, e.chunkSize); ok {
+		parts, chunked = chunks, true
+		e.chunks[node] = make([]string, len(chunks))
+		e.waiting[node] = len(chunks)
+	}
+	for i, part := range parts {
+		om := serialization.OperationMessage{
+			TaskID:    e.task.ID,
+			Node:      e.ids[node],
+			Operation: node.Value,
+			Operands:  part,
+			Mode:      e.task.Mode,
+			Timeout:   e.timeouts[rpn.Operations[node.Value]],
+		}
+		if chunked {
+			om.Chunk = i + 1
+		}
+		e.pending[operationKey{om.Node, om.Chunk}] = om
+		e.outbox = append(e.outbox, om)
+	}
+}
+
+// Функция, которая записывает значение узла и отправляет операции, которые его ждали.
+// Значение if - значение выбранной ветки
+func (e *execution) resolve(node *rpn.Node, value string) {
+	if e.done {
+		return
+	}
+	if _, ok := e.values[node]; ok {
+		return
+	}
+	e.values[node] = value
+	if node == e.tree {
+		e.done, e.result = true, value
+		return
+	}
+	for _, parent := range e.parents[node] {
+		if !e.requested[parent] {
+			continue
+		}
+		if parent.Kind == rpn.NodeFunction && parent.Value == rpn.If {
+			if branch := e.branches[parent]; branch != 0 && parent.Children[branch] == node {
+				e.resolve(parent, value)
+				continue
+			}
+		}
+		e.publish(parent)
+	}
+}
+
+// Функция, которая останавливает вычисление с ошибкой
+func (e *execution) fail(err error) {
+	if e.done {
+		return
+	}
+	e.done, e.err = true, err
+}
+
+// Функция, которая записывает результат операции от агента. Возвращает false, если результат уже
+// не нужен: операцию успел посчитать другой агент или вычисление закончилось
+func (e *execution) complete(rm serialization.ResultMessage) bool {
+	key := operationKey{rm.Node, rm.Chunk}
+	if e.done {
+		return false
+	}
+	if _, ok := e.pending[key]; !ok {
+		return false
+	}
+	delete(e.pending, key)
+	delete(e.agents, key)
+	e.trace = append(e.trace, rm.Trace...)
+	if rm.Status != storage.StatusTaskCompleted {
+		e.fail(errors.New(rm.Error))
+		return true
+	}
+
+	node := e.nodes[rm.Node]
+	value := rm.Result
+	if rm.Chunk != 0 {
+		e.chunks[node][rm.Chunk-1] = rm.Result
+		e.waiting[node]--
+		if e.waiting[node] > 0 {
+			return true
+		}
+		concatenated, err := numeric.Concat(e.chunks[node])
+		if err != nil {
+			e.fail(err)
+			return true
+		}
+		value = concatenated
+	}
+	e.completed++
+	if node.Kind == rpn.NodeFunction && node.Value == rpn.If {
+		branch := 2
+		if value == "1" {
+			branch = 1
+		}
+		e.branches[node] = branch
+		chosen := node.Children[branch]
+		e.request(chosen)
+		if value, ok := e.values[chosen]; ok {
+			e.resolve(node, value)
+		}
+		return true
+	}
+	e.resolve(node, value)
+	return true
+}
+
+// Функция, которая запоминает агента, начавшего считать операцию, и время начала операции узла.
+// Началом операции, которая считается по частям, считается начало первой части
+func (e *execution) assign(cm serialization.CalculatingMessage, at time.Time) {
+	key := operationKey{cm.Node, cm.Chunk}
+	if _, ok := e.pending[key]; !ok {
+		return
+	}
+	e.agents[key] = cm.AgentID
+	node := e.nodes[cm.Node]
+	if _, ok := e.started[node]; !ok {
+		e.started[node] = at
+	}
+}
+
+// Функция, которая возвращает в очередь операции агентов, для которых inactive вернула true.
+// Возвращает количество отправленных заново операций
+func (e *execution) reassign(inactive func(agentID uuid.UUID) bool) int {
+	republished := 0
+	for key, agentID := range e.agents {
+		if !inactive(agentID) {
+			continue
+		}
+		delete(e.agents, key)
+		e.outbox = append(e.outbox, e.pending[key])
+		republished++
+	}
+	if republished > 0 {
+		e.calculating = false
+	}
+	return republished
+}
+
+// Возвращает оставшееся время операции узла: у начатой операции - таймаут за вычетом прошедшего времени
+func (e *execution) Remaining(node *rpn.Node) (time.Duration, bool) {
+	if _, ok := e.values[node]; ok {
+		return 0, true
+	}
+	remaining := e.timeouts[rpn.Operations[node.Value]]
+	if started, ok := e.started[node]; ok {
+		remaining -= time.Since(started)
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, false
+}
+
+// Возвращает ветку if, выбранную условием
+func (e *execution) Branch(node *rpn.Node) int {
+	return e.branches[node]
+}
+
+// Запускает распределенное вычисление задачи: разбирает ее оптимизированное выражение и отправляет агентам
+// все операции, операнды которых уже известны. Возвращает ошибку, только если вычисление не удалось начать:
+// операции, которые не удалось отправить, остаются в outbox и отправляются при следующей проверке агентов
+func (o *Orchestrator) Schedule(task storage.Task) error {
+	parsed, err := o.parseTask(task, sourceOptimized)
+	if err != nil {
+		return err
+	}
+	e := newExecution(task, parsed.Tree, o.currentTimeouts(), o.ChunkSize)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.executions[task.ID] = e
+	e.start()
+	err = o.flush(e)
+	if err != nil {
+		log.Error("Error while publishing operations, will retry: " + err.Error())
+	}
+	return nil
+}
+
+// Запускает заново вычисление задач, которые не закончены, но не вычисляются: после перезапуска
+// оркестратора план вычисления задачи потерян, а после перезапуска брокера могли пропасть и ее операции.
+// Вызывается при запуске оркестратора. Результаты операций, которые остались в очередях, не пропадают:
+// если они приходят после повторного запуска, они засчитываются, как результаты тех же операций
+func (o *Orchestrator) Reconcile() error {
+	tasks, err := o.Storage.GetUnfinishedTasks()
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		o.mu.Lock()
+		_, running := o.executions[task.ID]
+		o.mu.Unlock()
+		if running {
+			continue
+		}
+		log.Info("Rescheduling unfinished task: " + task.ID.String())
+		if task.Status != storage.StatusTaskAccepted {
+			err = o.Storage.UpdateTaskStatus(task.ID, storage.StatusTaskRepublished)
+			if err != nil {
+				log.Error("Error while updating task status: " + err.Error())
+			}
+		}
+		err = o.Schedule(task)
+		if err != nil {
+			// Задачу, которую не удалось разобрать, уже не вычислить: она заканчивается с ошибкой разбора
+			log.Error("Error while scheduling task: " + err.Error())
+			err = o.Storage.FinishTask(task.ID, storage.StatusTaskInvalid, "", "", err.Error(), nil)
+			if err != nil {
+				log.Error("Error while updating task: " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Функция, которая записывает результат операции от агента и отправляет операции, которые от него зависят.
+// Повторно доставленный результат пропускается. Если прошлую доставку не удалось до конца обработать
+// (отправить операции или записать итог в бд), обработка продолжается
+func (o *Orchestrator) handleResult(rm serialization.ResultMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.executions[rm.ID]
+	if !ok {
+		log.Info("Skipping result of finished task: " + rm.ID.String())
+		return nil
+	}
+	if !e.complete(rm) && !e.done && len(e.outbox) == 0 {
+		log.Info("Skipping duplicate result: " + rm.String())
+		return nil
+	}
+	return o.flush(e)
+}
+
+// Функция, которая записывает в бд, что агент начал считать операцию выражения
+func (o *Orchestrator) handleCalculating(cm serialization.CalculatingMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.executions[cm.TaskID]
+	if !ok {
+		return nil
+	}
+	e.assign(cm, time.Now())
+	err := o.Storage.UpdateTaskAgentID(cm.TaskID, cm.AgentID)
+	if err != nil {
+		return err
+	}
+	if e.calculating {
+		return nil
+	}
+	err = o.Storage.UpdateTaskStatus(cm.TaskID, storage.StatusTaskCalculating)
+	if err != nil {
+		return err
+	}
+	if e.completed == 0 {
+		err = o.Storage.StartTaskProgress(cm.TaskID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	e.calculating = true
+	return nil
+}
+
+// Функция, которая возвращает в очередь операции неактивных агентов, а также повторяет отправку операций
+// и запись итогов задач, которые не удались раньше
+func (o *Orchestrator) reassignOperations(inactive func(agentID uuid.UUID) bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for id, e := range o.executions {
+		republished := e.reassign(inactive)
+		if republished > 0 {
+			log.Info("Republishing operations of task: " + id.String())
+			err := o.Storage.UpdateTaskStatus(id, storage.StatusTaskRepublished)
+			if err != nil {
+				log.Error("Error while updating task status: " + err.Error())
+			}
+		} else if len(e.outbox) == 0 && !e.done {
+			continue
+		}
+		err := o.flush(e)
+		if err != nil {
+			log.Error("Error while republishing operations: " + err.Error())
+		}
+	}
+}
+
+// Функция, которая отправляет готовые операции в очередь и записывает в бд ход вычисления,
+// а когда вычисление закончено - итог задачи. Неотправленные операции остаются в outbox, а вычисление
+// остается в списке, пока итог не записан, поэтому при ошибке flush можно вызвать снова.
+// Вызывается только под блокировкой o.mu
+func (o *Orchestrator) flush(e *execution) error {
+	for len(e.outbox) > 0 {
+		err := o.PublishOperation(e.outbox[0])
+		if err != nil {
+			return err
+		}
+		e.outbox = e.outbox[1:]
+	}
+
+	id := e.task.ID
+	if !e.done {
+		estimate := rpn.EstimateProgress(e.tree, e)
+		err := o.Storage.UpdateTaskProgress(id, e.completed, estimate.Operations, estimate.Duration, time.Now())
+		if err != nil {
+			log.Error("Error while updating task progress: " + err.Error())
+		}
+		return nil
+	}
+
+	status, res, unit, message := storage.StatusTaskCompleted, e.result, "", ""
+	if e.err != nil {
+		status, res, message = storage.StatusTaskInvalid, "", e.err.Error()
+	} else if _, u, err := rpn.SplitQuantity(res); err == nil {
+		unit = u.String()
+	}
+	err := o.Storage.FinishTask(id, status, res, unit, message, toTraceEntries(e.trace))
+	if err != nil {
+		return err
+	}
+	delete(o.executions, id)
+	log.Info("Successfully updated task: " + id.String() + " -> " + status)
+	return nil
+}
+
+// Отправляет операцию в очередь, из которой ее заберет свободный агент. Пока соединения с брокером нет,
+// возвращает ошибку, и операция остается в outbox до следующей попытки
+func (o *Orchestrator) PublishOperation(om serialization.OperationMessage) error {
+	serialized, err := serialization.Serialize[serialization.OperationMessage](om)
+	if err != nil {
+		return err
+	}
+	return o.Broker.Publish(broker.OperationsQueue, serialized)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oleg-top/go-orchestrator/broker"
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+// Создает вычисление выражения в заданном режиме и начинает его
+func startExecution(t *testing.T, expression, mode string, variables storage.Variables, chunkSize int) *execution {
+	t.Helper()
+	r, err := rpn.NewRPN(expression)
+	if err != nil {
+		t.Fatalf("NewRPN(%q): %v", expression, err)
+	}
+	task := storage.Task{ID: uuid.New(), Expression: expression, Mode: mode, Variables: variables}
+	e := newExecution(task, r.Tree, map[string]time.Duration{}, chunkSize)
+	e.start()
+	return e
+}
+
+// Считает операции вместо агентов волнами: все операции из outbox, затем операции, которые они освободили.
+// У if, как у агента, результат - "1" или "0". Возвращает количество волн и операций
+func runExecution(t *testing.T, e *execution) (waves, operations int) {
+	t.Helper()
+	for len(e.outbox) > 0 {
+		outbox := e.outbox
+		e.outbox = nil
+		waves++
+		for _, om := range outbox {
+			operations++
+			rm := serialization.ResultMessage{ID: om.TaskID, Node: om.Node, Chunk: om.Chunk, Status: storage.StatusTaskCompleted}
+			var res string
+			var err error
+			if om.Operation == rpn.If {
+				var truth bool
+				truth, err = numeric.Truth(om.Mode, om.Operands[0])
+				res = "0"
+				if truth {
+					res = "1"
+				}
+			} else {
+				res, err = numeric.Calculate(om.Mode, om.Operation, om.Operands)
+			}
+			if err != nil {
+				rm.Status, rm.Error = storage.StatusTaskInvalid, err.Error()
+			}
+			rm.Result = res
+			e.complete(rm)
+		}
+	}
+	return waves, operations
+}
+
+func TestExecution(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		mode       string
+		variables  storage.Variables
+		result     string
+		failed     bool
+		waves      int
+		operations int
+	}{
+		{"single", "2 + 3", numeric.ModeInt64, nil, "5", false, 1, 1},
+		{"parallel", "(a + b) * (c + d)", numeric.ModeInt64, storage.Variables{"a": "1", "b": "2", "c": "3", "d": "4"}, "21", false, 2, 3},
+		{"chain", "((1 + 2) * 3 - 4) / 5", numeric.ModeInt64, nil, "1", false, 4, 4},
+		{"shared", "a = x * y; a + a", numeric.ModeInt64, storage.Variables{"x": "2", "y": "3"}, "12", false, 2, 2},
+		{"if then", "if(x > 0, x * 2, 1 / 0)", numeric.ModeInt64, storage.Variables{"x": "4"}, "8", false, 3, 3},
+		{"if else", "if(x > 0, 1 / 0, -x)", numeric.ModeInt64, storage.Variables{"x": "-4"}, "4", false, 3, 3},
+		{"if known branch", "if(x > 0, x, 7)", numeric.ModeInt64, storage.Variables{"x": "-1"}, "7", false, 2, 2},
+		{"number", "42", numeric.ModeInt64, nil, "42", false, 0, 0},
+		{"variable", "x", numeric.ModeDecimal, storage.Variables{"x": "2.50"}, "2.5", false, 0, 0},
+		{"imaginary unit", "i * i", numeric.ModeComplex, nil, "-1", false, 1, 1},
+		{"error", "1 / (x - x) + x * 2", numeric.ModeInt64, storage.Variables{"x": "1"}, "", true, 2, 3},
+		{"missing variable", "x + 1", numeric.ModeInt64, nil, "", true, 0, 0},
+		{"invalid variable", "x + 1", numeric.ModeInt64, storage.Variables{"x": "1.5"}, "", true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := startExecution(t, tt.expression, tt.mode, tt.variables, defaultChunkSize)
+			waves, operations := runExecution(t, e)
+			if !e.done {
+				t.Fatal("execution is not done")
+			}
+			if (e.err != nil) != tt.failed {
+				t.Fatalf("err = %v, want failed %v", e.err, tt.failed)
+			}
+			if e.result != tt.result {
+				t.Errorf("result = %q, want %q", e.result, tt.result)
+			}
+			if waves != tt.waves || operations != tt.operations {
+				t.Errorf("waves, operations = %d, %d, want %d, %d", waves, operations, tt.waves, tt.operations)
+			}
+		})
+	}
+}
+
+func TestExecutionChunks(t *testing.T) {
+	e := startExecution(t, "v * 2 + 1", numeric.ModeInt64, storage.Variables{"v": "[1, 2, 3, 4, 5]"}, 2)
+	if len(e.outbox) != 3 {
+		t.Fatalf("len(outbox) = %d, want 3 chunks", len(e.outbox))
+	}
+	_, operations := runExecution(t, e)
+	if e.err != nil {
+		t.Fatal(e.err)
+	}
+	if e.result != "[3, 5, 7, 9, 11]" {
+		t.Errorf("result = %q, want [3, 5, 7, 9, 11]", e.result)
+	}
+	if operations != 6 {
+		t.Errorf("operations = %d, want 6", operations)
+	}
+}
+
+// Повторная доставка результата и результат операции, которую уже посчитал другой агент, не засчитываются
+func TestExecutionDuplicateResult(t *testing.T) {
+	e := startExecution(t, "(1 + 2) * (3 + 4)", numeric.ModeInt64, nil, defaultChunkSize)
+	om := e.outbox[0]
+	rm := serialization.ResultMessage{ID: om.TaskID, Node: om.Node, Result: "3", Status: storage.StatusTaskCompleted}
+	if !e.complete(rm) {
+		t.Fatal("first result is not accepted")
+	}
+	if e.complete(rm) {
+		t.Error("duplicate result is accepted")
+	}
+	if e.completed != 1 {
+		t.Errorf("completed = %d, want 1", e.completed)
+	}
+	rm.Node = 0
+	if e.complete(rm) {
+		t.Error("result of an unknown operation is accepted")
+	}
+}
+
+// Операции неактивного агента снова попадают в outbox, а операции активных агентов - нет
+func TestExecutionReassign(t *testing.T) {
+	e := startExecution(t, "(1 + 2) * (3 + 4)", numeric.ModeInt64, nil, defaultChunkSize)
+	alive, dead := uuid.New(), uuid.New()
+	first, second := e.outbox[0], e.outbox[1]
+	e.outbox = nil
+	now := time.Now()
+	e.assign(serialization.CalculatingMessage{AgentID: alive, TaskID: first.TaskID, Node: first.Node}, now)
+	e.assign(serialization.CalculatingMessage{AgentID: dead, TaskID: second.TaskID, Node: second.Node}, now)
+
+	republished := e.reassign(func(agentID uuid.UUID) bool { return agentID == dead })
+	if republished != 1 || len(e.outbox) != 1 || e.outbox[0].Node != second.Node {
+		t.Fatalf("reassign() = %d, outbox = %v, want the operation of the inactive agent", republished, e.outbox)
+	}
+	if _, ok := e.started[e.nodes[first.Node]]; !ok {
+		t.Error("start of the assigned operation is not recorded")
+	}
+
+	runExecution(t, e)
+	if e.done {
+		t.Fatal("execution is done without the result of the active agent")
+	}
+	e.complete(serialization.ResultMessage{ID: first.TaskID, Node: first.Node, Result: "3", Status: storage.StatusTaskCompleted})
+	runExecution(t, e)
+	if e.result != "21" {
+		t.Errorf("result = %q, want 21", e.result)
+	}
+}
+
+// Записывает задачу в бд и возвращает ее так, как ее читает оркестратор
+func addTestTask(t *testing.T, o *Orchestrator, expression string, variables storage.Variables) storage.Task {
+	t.Helper()
+	id, err := o.Storage.AddTask(storage.Task{Expression: expression, Mode: numeric.ModeInt64, Variables: variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getTestTask(t, o, id)
+}
+
+func getTestTask(t *testing.T, o *Orchestrator, id uuid.UUID) storage.Task {
+	t.Helper()
+	tasks, err := o.Storage.GetTaskById(id)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetTaskById() = %v, %v", tasks, err)
+	}
+	return tasks[0]
+}
+
+// Доставляет оркестратору результаты всех операций задачи, как будто брокер отправил их агентам.
+// Возвращает результаты в порядке доставки
+func deliverResults(t *testing.T, o *Orchestrator, id uuid.UUID) []serialization.ResultMessage {
+	t.Helper()
+	var delivered []serialization.ResultMessage
+	for {
+		o.mu.Lock()
+		e, ok := o.executions[id]
+		var outbox []serialization.OperationMessage
+		if ok {
+			outbox, e.outbox = e.outbox, nil
+		}
+		o.mu.Unlock()
+		if len(outbox) == 0 {
+			return delivered
+		}
+		for _, om := range outbox {
+			res, err := numeric.Calculate(om.Mode, om.Operation, om.Operands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rm := serialization.ResultMessage{ID: om.TaskID, Node: om.Node, Chunk: om.Chunk, Result: res, Status: storage.StatusTaskCompleted}
+			// Без брокера новые операции не отправляются и остаются в outbox
+			if err := o.handleResult(rm); err != nil && !errors.Is(err, broker.ErrDisconnected) {
+				t.Fatalf("handleResult(): %v", err)
+			}
+			delivered = append(delivered, rm)
+		}
+	}
+}
+
+// Итог задачи записывается один раз, а повторная доставка результата ничего не меняет
+func TestHandleResult(t *testing.T) {
+	o := newTestOrchestrator(t)
+	task := addTestTask(t, o, "(x + 1) * (x + 2)", storage.Variables{"x": "3"})
+	if err := o.Schedule(task); err != nil {
+		t.Fatal(err)
+	}
+	delivered := deliverResults(t, o, task.ID)
+	if len(delivered) != 3 {
+		t.Fatalf("delivered %d results, want 3", len(delivered))
+	}
+	finished := getTestTask(t, o, task.ID)
+	if finished.Status != storage.StatusTaskCompleted || finished.Result != "20" {
+		t.Fatalf("task = %s %q, want %s 20", finished.Status, finished.Result, storage.StatusTaskCompleted)
+	}
+	if _, ok := o.executions[task.ID]; ok {
+		t.Error("finished task is still executing")
+	}
+
+	for _, rm := range delivered {
+		rm.Result = "0"
+		if err := o.handleResult(rm); err != nil {
+			t.Errorf("handleResult() of a redelivered result: %v", err)
+		}
+	}
+	if again := getTestTask(t, o, task.ID); again.Result != "20" {
+		t.Errorf("result after redelivery = %q, want 20", again.Result)
+	}
+}
+
+// После перезапуска оркестратора незаконченные задачи вычисляются заново, а законченные - нет.
+// Задача, которую не удалось разобрать, заканчивается с ошибкой
+func TestReconcile(t *testing.T) {
+	o := newTestOrchestrator(t)
+	accepted := addTestTask(t, o, "1 + 2", nil)
+	calculating := addTestTask(t, o, "3 * 4", nil)
+	if err := o.Storage.UpdateTaskStatus(calculating.ID, storage.StatusTaskCalculating); err != nil {
+		t.Fatal(err)
+	}
+	finished := addTestTask(t, o, "5 - 6", nil)
+	if err := o.Storage.FinishTask(finished.ID, storage.StatusTaskCompleted, "-1", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Определение функции не сохранено вместе с задачей, поэтому выражение не разбирается
+	broken := addTestTask(t, o, "double(3)", nil)
+
+	if err := o.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []storage.Task{accepted, calculating} {
+		if _, ok := o.executions[task.ID]; !ok {
+			t.Errorf("task %q is not rescheduled", task.Expression)
+		}
+	}
+	if _, ok := o.executions[finished.ID]; ok {
+		t.Error("finished task is rescheduled")
+	}
+	if got := getTestTask(t, o, broken.ID); got.Status != storage.StatusTaskInvalid || got.Error == "" {
+		t.Errorf("task %q = %s %q, want %s with the parse error", broken.Expression, got.Status, got.Error, storage.StatusTaskInvalid)
+	}
+	if _, ok := o.executions[broken.ID]; ok {
+		t.Error("task that does not parse is executing")
+	}
+	if got := getTestTask(t, o, accepted.ID).Status; got != storage.StatusTaskAccepted {
+		t.Errorf("status of the accepted task = %s, want %s", got, storage.StatusTaskAccepted)
+	}
+	if got := getTestTask(t, o, calculating.ID).Status; got != storage.StatusTaskRepublished {
+		t.Errorf("status of the calculating task = %s, want %s", got, storage.StatusTaskRepublished)
+	}
+
+	// Задача, которая уже вычисляется, не запускается второй раз
+	e := o.executions[accepted.ID]
+	if err := o.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if o.executions[accepted.ID] != e {
+		t.Error("running task is rescheduled")
+	}
+
+	deliverResults(t, o, accepted.ID)
+	if got := getTestTask(t, o, accepted.ID); got.Status != storage.StatusTaskCompleted || got.Result != "3" {
+		t.Errorf("task = %s %q, want %s 3", got.Status, got.Result, storage.StatusTaskCompleted)
+	}
+}
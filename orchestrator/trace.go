@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+// Операция из трассировки вместе со временем начала и конца в миллисекундах от начала первой операции
+type traceEntryView struct {
+	Node       int      `json:"node"`
+	Operation  string   `json:"operation"`
+	Operands   []string `json:"operands"`
+	Result     string   `json:"result,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	StartedAt  string   `json:"started_at"`
+	FinishedAt string   `json:"finished_at"`
+	StartMs    int64    `json:"start_ms"`
+	EndMs      int64    `json:"end_ms"`
+	Agent      string   `json:"agent,omitempty"`
+	Slot       int      `json:"slot"`
+	Chunk      int      `json:"chunk,omitempty"`
+}
+
+// Получение трассировки выражения: всех операций, выполненных агентами, и того, сколько из них шло параллельно
+func (o *Orchestrator) GetExpressionTrace(w http.ResponseWriter, r *http.Request) {
+	validID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error while parsing id: " + err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tasks, err := o.Storage.GetTaskById(validID)
+	if err != nil {
+		log.Error("Error while getting expression by id: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(tasks) == 0 {
+		http.Error(w, "Expression not found", http.StatusNotFound)
+		return
+	}
+	entries, err := o.Storage.GetTrace(validID)
+	if err != nil {
+		log.Error("Error while getting trace: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := newTraceViews(entries)
+	var duration int64
+	for _, view := range views {
+		if view.EndMs > duration {
+			duration = view.EndMs
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]any{
+		"id":           tasks[0].ID,
+		"status":       tasks[0].Status,
+		"operations":   views,
+		"duration_ms":  duration,
+		"max_parallel": maxParallel(entries),
+	})
+	if err != nil {
+		log.Error("Error while encoding json: " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Переводит трассировку из сообщений агентов в формат хранилища
+func toTraceEntries(trace []serialization.TraceEntry) []storage.TraceEntry {
+	entries := make([]storage.TraceEntry, len(trace))
+	for i, entry := range trace {
+		entries[i] = storage.TraceEntry{
+			Node:       entry.Node,
+			Operation:  entry.Operation,
+			Operands:   entry.Operands,
+			Result:     entry.Result,
+			Error:      entry.Error,
+			StartedAt:  entry.Start.UTC().Format(storage.TraceTimeLayout),
+			FinishedAt: entry.End.UTC().Format(storage.TraceTimeLayout),
+			AgentID:    entry.Agent,
+			Slot:       entry.Slot,
+			Chunk:      entry.Chunk,
+		}
+	}
+	return entries
+}
+
+// Отсчитывает время операций от начала первой из них
+func newTraceViews(entries []storage.TraceEntry) []traceEntryView {
+	views := make([]traceEntryView, len(entries))
+	if len(entries) == 0 {
+		return views
+	}
+	first, _ := time.Parse(storage.TraceTimeLayout, entries[0].StartedAt)
+	for i, entry := range entries {
+		start, _ := time.Parse(storage.TraceTimeLayout, entry.StartedAt)
+		end, _ := time.Parse(storage.TraceTimeLayout, entry.FinishedAt)
+		views[i] = traceEntryView{
+			Node:       entry.Node,
+			Operation:  entry.Operation,
+			Operands:   entry.Operands,
+			Result:     entry.Result,
+			Error:      entry.Error,
+			StartedAt:  entry.StartedAt,
+			FinishedAt: entry.FinishedAt,
+			StartMs:    start.Sub(first).Milliseconds(),
+			EndMs:      end.Sub(first).Milliseconds(),
+			Slot:       entry.Slot,
+			Chunk:      entry.Chunk,
+		}
+		if entry.AgentID != uuid.Nil {
+			views[i].Agent = entry.AgentID.String()
+		}
+	}
+	return views
+}
+
+// Возвращает наибольшее количество операций, которые выполнялись одновременно. Операция, закончившаяся
+// в тот же момент, когда началась другая, с ней не пересекается
+func maxParallel(entries []storage.TraceEntry) int {
+	type event struct {
+		at    string
+		delta int
+	}
+	events := make([]event, 0, 2*len(entries))
+	for _, entry := range entries {
+		events = append(events, event{entry.StartedAt, 1}, event{entry.FinishedAt, -1})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at != events[j].at {
+			return events[i].at < events[j].at
+		}
+		return events[i].delta < events[j].delta
+	})
+	current, best := 0, 0
+	for _, e := range events {
+		current += e.delta
+		if current > best {
+			best = current
+		}
+	}
+	return best
+}
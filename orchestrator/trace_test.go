@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oleg-top/go-orchestrator/db/storage"
+	"github.com/oleg-top/go-orchestrator/serialization"
+)
+
+func TestMaxParallel(t *testing.T) {
+	// Операция с началом и концом в миллисекундах
+	type span struct{ start, end int }
+	tests := []struct {
+		name  string
+		spans []span
+		want  int
+	}{
+		{"empty", nil, 0},
+		{"one", []span{{0, 10}}, 1},
+		{"sequential", []span{{0, 10}, {10, 20}, {20, 30}}, 1},
+		{"overlapping", []span{{0, 10}, {5, 15}, {8, 20}, {16, 30}}, 3},
+		{"same start", []span{{0, 10}, {0, 10}}, 2},
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) string {
+		return base.Add(time.Duration(ms) * time.Millisecond).Format(storage.TraceTimeLayout)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := make([]storage.TraceEntry, len(tt.spans))
+			for i, s := range tt.spans {
+				entries[i] = storage.TraceEntry{StartedAt: at(s.start), FinishedAt: at(s.end)}
+			}
+			if got := maxParallel(entries); got != tt.want {
+				t.Errorf("maxParallel() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// Трассировка из сообщений агентов записывается вместе с итогом задачи и отдается отсчитанной от начала
+// первой операции. Повторная запись итога не меняет трассировку
+func TestExpressionTrace(t *testing.T) {
+	o := newTestOrchestrator(t)
+	task := addTestTask(t, o, "(1 + 2) * 3", nil)
+	agent := uuid.New()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	trace := []serialization.TraceEntry{
+		{Node: 2, Operation: "+", Operands: []string{"1", "2"}, Result: "3", Start: start, End: start.Add(20 * time.Millisecond), Agent: agent},
+		{Node: 1, Operation: "*", Operands: []string{"3", "3"}, Result: "9", Start: start.Add(25 * time.Millisecond), End: start.Add(40 * time.Millisecond), Agent: agent, Slot: 1},
+	}
+	if err := o.Storage.FinishTask(task.ID, storage.StatusTaskCompleted, "9", "", "", toTraceEntries(trace)); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Storage.FinishTask(task.ID, storage.StatusTaskCompleted, "9", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	o.Router.ServeHTTP(recorder, httptest.NewRequest("GET", "/expressions/"+task.ID.String()+"/trace", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Operations  []traceEntryView `json:"operations"`
+		DurationMs  int64            `json:"duration_ms"`
+		MaxParallel int              `json:"max_parallel"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Operations) != 2 {
+		t.Fatalf("operations = %+v, want 2", response.Operations)
+	}
+	first, second := response.Operations[0], response.Operations[1]
+	if first.Node != 2 || first.StartMs != 0 || first.EndMs != 20 || first.Agent != agent.String() {
+		t.Errorf("first operation = %+v", first)
+	}
+	if second.Node != 1 || second.StartMs != 25 || second.EndMs != 40 || second.Slot != 1 {
+		t.Errorf("second operation = %+v", second)
+	}
+	if response.DurationMs != 40 || response.MaxParallel != 1 {
+		t.Errorf("duration_ms, max_parallel = %d, %d, want 40, 1", response.DurationMs, response.MaxParallel)
+	}
+
+	recorder = httptest.NewRecorder()
+	o.Router.ServeHTTP(recorder, httptest.NewRequest("GET", "/expressions/"+uuid.NewString()+"/trace", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status of an unknown expression = %d, want 404", recorder.Code)
+	}
+}
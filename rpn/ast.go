@@ -0,0 +1,163 @@
+package rpn
+
+// Типы узлов дерева выражения
+type NodeKind int
+
+const (
+	NodeNumber NodeKind = iota
+	NodeUnary
+	NodeBinary
+	NodeFunction
+	NodeVariable
+)
+
+// Возвращает название типа узла
+func (k NodeKind) String() string {
+	switch k {
+	case NodeNumber:
+		return "number"
+	case NodeUnary:
+		return "unary"
+	case NodeBinary:
+		return "binary"
+	case NodeFunction:
+		return "function"
+	case NodeVariable:
+		return "variable"
+	}
+	return "unknown"
+}
+
+// Узел дерева выражения. Для чисел Value хранит само число, для переменных - имя переменной,
+// для операций - знак операции, для функций - имя функции, а ее аргументы лежат в Children
+type Node struct {
+	Kind     NodeKind
+	Value    string
+	Children []*Node
+	Pos      int
+}
+
+// Названия операций, по которым агенту передаются таймауты
+var Operations = map[string]string{
+	"+":         "add",
+	"-":         "sub",
+	"*":         "mul",
+	"/":         "div",
+	"^":         "pow",
+	"%":         "mod",
+	"//":        "idiv",
+	Negation:    "neg",
+	"<":         "lt",
+	"<=":        "le",
+	"==":        "eq",
+	"!=":        "ne",
+	">=":        "ge",
+	">":         "gt",
+	And:         "and",
+	Or:          "or",
+	Not:         "not",
+	If:          "if",
+	"abs":       "abs",
+	"sqrt":      "sqrt",
+	"min":       "min",
+	"max":       "max",
+	"round":     "round",
+	"conj":      "conj",
+	"re":        "re",
+	"im":        "im",
+	Vector:      "vector",
+	"dot":       "dot",
+	"matmul":    "matmul",
+	"transpose": "transpose",
+	"sum":       "sum",
+	To:          "to",
+	SI:          "si",
+}
+
+// Допустимое количество аргументов встроенной функции. MaxArgs равен -1, если аргументов может быть сколько угодно
+type Function struct {
+	MinArgs int
+	MaxArgs int
+}
+
+// Встроенные функции
+var Functions = map[string]Function{
+	"abs":       {MinArgs: 1, MaxArgs: 1},
+	"sqrt":      {MinArgs: 1, MaxArgs: 1},
+	"min":       {MinArgs: 1, MaxArgs: -1},
+	"max":       {MinArgs: 1, MaxArgs: -1},
+	"round":     {MinArgs: 1, MaxArgs: 2},
+	"conj":      {MinArgs: 1, MaxArgs: 1},
+	"re":        {MinArgs: 1, MaxArgs: 1},
+	"im":        {MinArgs: 1, MaxArgs: 1},
+	If:          {MinArgs: 3, MaxArgs: 3},
+	Vector:      {MinArgs: 1, MaxArgs: -1},
+	"dot":       {MinArgs: 2, MaxArgs: 2},
+	"matmul":    {MinArgs: 2, MaxArgs: 2},
+	"transpose": {MinArgs: 1, MaxArgs: 1},
+	"sum":       {MinArgs: 1, MaxArgs: 1},
+	To:          {MinArgs: 2, MaxArgs: 2},
+	SI:          {MinArgs: 1, MaxArgs: 1},
+}
+
+// Проверяет, подходит ли функции заданное количество аргументов
+func (f Function) Accepts(args int) bool {
+	return args >= f.MinArgs && (f.MaxArgs == -1 || args <= f.MaxArgs)
+}
+
+// Проверяет, может ли функция принимать разное количество аргументов
+func (f Function) IsVariadic() bool {
+	return f.MinArgs != f.MaxArgs
+}
+
+// Проверяет, является ли узел операцией, которую нужно вычислить
+func (n *Node) IsOperation() bool {
+	return n.Kind != NodeNumber && n.Kind != NodeVariable
+}
+
+// Возвращает узлы всех переменных выражения
+func (n *Node) Variables() []*Node {
+	var variables []*Node
+	n.Walk(func(node *Node) error {
+		if node.Kind == NodeVariable {
+			variables = append(variables, node)
+		}
+		return nil
+	})
+	return variables
+}
+
+// Обходит дерево, вызывая fn для каждого узла, пока fn не вернет ошибку. Узел, на который ссылаются
+// несколько операций, обходится один раз
+func (n *Node) Walk(fn func(node *Node) error) error {
+	return n.walk(fn, make(map[*Node]bool))
+}
+
+// Нумерует узлы дерева с единицы в порядке обхода Walk. Одно и то же выражение всегда нумеруется одинаково,
+// поэтому по номеру можно найти узел в дереве, разобранном заново, например на другом сервисе
+func (n *Node) IDs() map[*Node]int {
+	ids := make(map[*Node]int)
+	n.Walk(func(node *Node) error {
+		ids[node] = len(ids) + 1
+		return nil
+	})
+	return ids
+}
+
+func (n *Node) walk(fn func(node *Node) error, visited map[*Node]bool) error {
+	if visited[n] {
+		return nil
+	}
+	visited[n] = true
+	err := fn(n)
+	if err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		err = child.walk(fn, visited)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
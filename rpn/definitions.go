@@ -0,0 +1,277 @@
+package rpn
+
+import (
+	"sort"
+	"strings"
+)
+
+// Пользовательская функция, заданная выражением, например "hyp(a, b) = sqrt(a*a + b*b)"
+type Definition struct {
+	Name   string
+	Params []string
+	Source string
+	Body   *Node
+}
+
+// Пользовательские функции по именам
+type Definitions map[string]*Definition
+
+// Заголовок определения функции и имена пользовательских функций, которые вызываются в ее теле
+type header struct {
+	name   string
+	params []string
+	body   string
+	// Смещение тела функции от начала определения
+	offset int
+	calls  []string
+}
+
+// Возвращает имя функции из ее определения
+func DefinitionName(source string) (string, error) {
+	h, err := parseHeader(source)
+	if err != nil {
+		return "", err
+	}
+	return h.name, nil
+}
+
+// Разбирает набор определений функций, которые могут вызывать друг друга в любом порядке.
+// В теле функции можно вызывать встроенные функции и другие функции из набора.
+// Возвращает ошибку, если функции вызывают друг друга рекурсивно
+func ParseDefinitions(sources []string) (Definitions, error) {
+	headers := make(map[string]*header, len(sources))
+	origins := make(map[string]string, len(sources))
+	for _, source := range sources {
+		h, err := parseHeader(source)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := headers[h.name]; ok {
+			return nil, &ParseError{
+				Code:    ErrDuplicateFunction,
+				Message: "Функция определена несколько раз",
+				Token:   h.name,
+			}
+		}
+		headers[h.name] = h
+		origins[h.name] = source
+	}
+
+	// Обход в глубину: функции разбираются только после всех функций, которые они вызывают
+	definitions := make(Definitions, len(headers))
+	visiting := make(map[string]bool)
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := definitions[name]; ok {
+			return nil
+		}
+		if visiting[name] {
+			cycle := append(append([]string{}, path[indexOf(path, name):]...), name)
+			return &ParseError{
+				Code:    ErrRecursion,
+				Message: "Рекурсивное определение функции: " + strings.Join(cycle, " -> "),
+				Token:   name,
+			}
+		}
+		visiting[name] = true
+		path = append(path, name)
+		h := headers[name]
+		for _, call := range h.calls {
+			if _, ok := headers[call]; ok {
+				err := visit(call)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		definition, err := h.parse(origins[name], definitions)
+		if err != nil {
+			return err
+		}
+		definitions[name] = definition
+		path = path[:len(path)-1]
+		visiting[name] = false
+		return nil
+	}
+
+	// Имена сортируются, чтобы при нескольких ошибках всегда возвращалась одна и та же
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := visit(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return definitions, nil
+}
+
+// Возвращает тексты определений функций
+func (d Definitions) Sources() []string {
+	sources := make([]string, 0, len(d))
+	for _, definition := range d {
+		sources = append(sources, definition.Source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Выбирает из определений функций, записанных по именам, те, которые могут понадобиться выражению: функции,
+// имена которых встречаются в выражении, и функции, которые встречаются в их телах. Тогда ошибка
+// в определении, которое выражение не использует, не мешает его разобрать
+func SelectDefinitions(expression string, sources map[string]string) []string {
+	var selected []string
+	seen := make(map[string]bool)
+	queue := []string{expression}
+	for len(queue) > 0 {
+		// Единицы измерения не склеиваются с числами, чтобы не пропустить функцию с именем единицы
+		tokens, _ := tokenize(queue[0], false)
+		queue = queue[1:]
+		for _, token := range tokens {
+			source, ok := sources[token.Text]
+			if token.Type != TokenIdentifier || !ok || seen[token.Text] {
+				continue
+			}
+			seen[token.Text] = true
+			selected = append(selected, source)
+			queue = append(queue, source)
+		}
+	}
+	return selected
+}
+
+// Разбирает заголовок определения "имя(параметр, ...) =" и находит вызовы функций в теле
+func parseHeader(source string) (*header, error) {
+	tokens, err := Tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	invalid := func(message string, pos int) error {
+		token := Token{Pos: pos}
+		if pos < len(tokens) {
+			token = tokens[pos]
+		} else if len(tokens) > 0 {
+			token = tokens[len(tokens)-1]
+		}
+		return newParseError(ErrInvalidDefinition, message, token)
+	}
+
+	if len(tokens) < 2 || tokens[0].Type != TokenIdentifier || tokens[1].Type != TokenLeftParen {
+		return nil, invalid("Определение должно начинаться с имени функции и скобки: f(x) = ...", 0)
+	}
+	h := &header{name: tokens[0].Text}
+	if _, ok := Functions[h.name]; ok {
+		return nil, newParseError(ErrDuplicateFunction, "Нельзя переопределить встроенную функцию", tokens[0])
+	}
+
+	i := 2
+	for {
+		if i >= len(tokens) || tokens[i].Type != TokenIdentifier {
+			return nil, invalid("Ожидалось имя параметра", i)
+		}
+		param := tokens[i].Text
+		if _, ok := Functions[param]; ok || indexOf(h.params, param) != -1 {
+			return nil, newParseError(ErrInvalidDefinition, "Недопустимое имя параметра", tokens[i])
+		}
+		h.params = append(h.params, param)
+		i++
+		if i < len(tokens) && tokens[i].Type == TokenComma {
+			i++
+			continue
+		}
+		break
+	}
+	if i >= len(tokens) || tokens[i].Type != TokenRightParen {
+		return nil, invalid("Ожидалась закрывающая скобка после параметров", i)
+	}
+	i++
+	if i >= len(tokens) || tokens[i].Type != TokenAssign {
+		return nil, invalid("Ожидался знак = после параметров", i)
+	}
+	i++
+	if i >= len(tokens) {
+		return nil, invalid("Пустое тело функции", i)
+	}
+
+	h.offset = tokens[i].Pos
+	h.body = source[h.offset:]
+	for j := i; j+1 < len(tokens); j++ {
+		if tokens[j].Type == TokenIdentifier && tokens[j+1].Type == TokenLeftParen {
+			h.calls = append(h.calls, tokens[j].Text)
+		}
+	}
+	return h, nil
+}
+
+// Разбирает тело функции, в котором можно использовать только ее параметры. Имя i, если это не параметр,
+// означает мнимую единицу, чтобы значение переменной i из выражения не попадало в тело функции
+func (h *header) parse(source string, definitions Definitions) (*Definition, error) {
+	body, err := Parse(h.body, Options{Functions: definitions})
+	if err != nil {
+		if parseErr, ok := err.(*ParseError); ok {
+			parseErr.Pos += h.offset
+		}
+		return nil, err
+	}
+	for _, variable := range body.Tree.Variables() {
+		switch {
+		case indexOf(h.params, variable.Value) != -1:
+		case variable.Value == ImaginaryUnit:
+			variable.Kind = NodeNumber
+		default:
+			return nil, &ParseError{
+				Code:    ErrUnboundVariable,
+				Message: "В теле функции можно использовать только ее параметры",
+				Token:   variable.Value,
+				Pos:     variable.Pos + h.offset,
+			}
+		}
+	}
+	return &Definition{
+		Name:   h.name,
+		Params: h.params,
+		Source: strings.TrimSpace(source),
+		Body:   body.Tree,
+	}, nil
+}
+
+// Строит дерево вызова функции: копию тела, в которой параметры заменены деревьями аргументов.
+// Все узлы копии получают позицию вызова, чтобы ошибки указывали на место в выражении.
+// Общие узлы тела остаются общими и в копии
+func (d *Definition) instantiate(args []*Node, pos int) *Node {
+	values := make(map[string]*Node, len(args))
+	for i, param := range d.Params {
+		values[param] = args[i]
+	}
+	copies := make(map[*Node]*Node)
+	var substitute func(node *Node) *Node
+	substitute = func(node *Node) *Node {
+		if node.Kind == NodeVariable {
+			return values[node.Value]
+		}
+		if copied, ok := copies[node]; ok {
+			return copied
+		}
+		copied := &Node{Kind: node.Kind, Value: node.Value, Pos: pos}
+		for _, child := range node.Children {
+			copied.Children = append(copied.Children, substitute(child))
+		}
+		copies[node] = copied
+		return copied
+	}
+	return substitute(d.Body)
+}
+
+// Возвращает индекс строки в срезе или -1, если ее там нет
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
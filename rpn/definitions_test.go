@@ -0,0 +1,95 @@
+package rpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectDefinitions(t *testing.T) {
+	sources := map[string]string{
+		"f":      "f(x) = g(x) * 2",
+		"g":      "g(x) = x + 1",
+		"h":      "h(x) = x - 1",
+		"broken": "broken(x) = y",
+		"m":      "m(x) = x * 3",
+	}
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{"1 + 2", nil},
+		{"f(3)", []string{sources["f"], sources["g"]}},
+		{"h(1) + g(2)", []string{sources["h"], sources["g"]}},
+		{"broken(1)", []string{sources["broken"]}},
+		// В постфиксной записи функция может называться так же, как единица измерения
+		{"2 m", []string{sources["m"]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got := SelectDefinitions(tt.expression, sources)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectDefinitions(%q) = %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDefinitionsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		code    string
+	}{
+		{"unbound", []string{"f(x) = y"}, ErrUnboundVariable},
+		{"builtin", []string{"abs(x) = x"}, ErrDuplicateFunction},
+		{"duplicate", []string{"f(x) = x", "f(y) = y"}, ErrDuplicateFunction},
+		{"recursion", []string{"f(x) = g(x)", "g(x) = f(x)"}, ErrRecursion},
+		{"header", []string{"f = 2"}, ErrInvalidDefinition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDefinitions(tt.sources)
+			parseErr, ok := err.(*ParseError)
+			if !ok || parseErr.Code != tt.code {
+				t.Errorf("ParseDefinitions() error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestParseWithFunctions(t *testing.T) {
+	definitions, err := ParseDefinitions([]string{
+		"hyp(a, b) = sqrt(a * a + b * b)",
+		"double(x) = x * 2",
+		"quad(x) = double(double(x))",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expression string
+		infix      string
+	}{
+		{"hyp(3, 4)", "sqrt(3 * 3 + 4 * 4)"},
+		{"double(y + 1)", "(y + 1) * 2"},
+		{"quad(y)", "y * 2 * 2"},
+		// Аргумент подставляется один раз, даже если параметр встречается в теле несколько раз
+		{"hyp(x + 1, 0)", "_1 = x + 1; sqrt(_1 * _1 + 0 * 0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := Parse(tt.expression, Options{Functions: definitions})
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expression, err)
+			}
+			if got := Infix(r.Tree); got != tt.infix {
+				t.Errorf("Infix() = %q, want %q", got, tt.infix)
+			}
+		})
+	}
+
+	_, err = Parse("double(1, 2)", Options{Functions: definitions})
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Code != ErrInvalidArity {
+		t.Errorf("Parse(double(1, 2)) error = %v, want %s", err, ErrInvalidArity)
+	}
+}
@@ -0,0 +1,51 @@
+package rpn
+
+import "fmt"
+
+// Коды ошибок разбора выражения
+const (
+	ErrEmptyExpression   = "empty_expression"
+	ErrUnknownSymbol     = "unknown_symbol"
+	ErrInvalidNumber     = "invalid_number"
+	ErrMisplacedOperator = "misplaced_operator"
+	ErrMissingOperator   = "missing_operator"
+	ErrTrailingOperator  = "trailing_operator"
+	ErrEmptyParentheses  = "empty_parentheses"
+	ErrUnmatchedParen    = "unmatched_paren"
+	ErrUnclosedParen     = "unclosed_paren"
+	ErrUnknownIdentifier = "unknown_identifier"
+	ErrInvalidCall       = "invalid_function_call"
+	ErrInvalidArity      = "invalid_arity"
+	ErrMisplacedComma    = "misplaced_comma"
+	ErrUnboundVariable   = "unbound_variable"
+	ErrInvalidBinding    = "invalid_binding"
+	ErrInvalidDefinition = "invalid_definition"
+	ErrDuplicateFunction = "duplicate_function"
+	ErrRecursion         = "recursive_function"
+	ErrEmptyStatement    = "empty_statement"
+	ErrInvalidStatement  = "invalid_statement"
+	ErrMissingOperand    = "missing_operand"
+	ErrExtraOperand      = "extra_operand"
+	ErrUnknownNotation   = "unknown_notation"
+)
+
+// Ошибка разбора выражения с кодом и позицией токена, на котором она произошла
+type ParseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Token   string `json:"token"`
+	Pos     int    `json:"position"`
+}
+
+// Возвращает строковое представление ошибки
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s (позиция %d)", e.Message, e.Pos)
+	}
+	return fmt.Sprintf("%s: %q (позиция %d)", e.Message, e.Token, e.Pos)
+}
+
+// Создает ошибку разбора для заданного токена
+func newParseError(code, message string, token Token) *ParseError {
+	return &ParseError{Code: code, Message: message, Token: token.Text, Pos: token.Pos}
+}
@@ -0,0 +1,86 @@
+package rpn
+
+import "time"
+
+// Оценка времени вычисления дерева
+type Estimate struct {
+	// Длина критического пути с учетом времени каждой операции
+	Duration time.Duration
+	// Количество операций, которые еще нужно выполнить
+	Operations int
+}
+
+// Состояние вычисления, по которому оценивается оставшееся время
+type EstimateState interface {
+	// Возвращает оставшееся время операции узла и признак того, что узел уже вычислен
+	Remaining(node *Node) (time.Duration, bool)
+	// Возвращает ветку if, которую выбрало условие (1 или 2), или 0, если условие еще не проверено
+	Branch(node *Node) int
+}
+
+// Оценивает время вычисления дерева, которое еще не начали считать: каждая операция длится
+// свой таймаут, а независимые операции выполняются параллельно
+func EstimateDuration(tree *Node, timeouts map[string]time.Duration) Estimate {
+	return EstimateProgress(tree, timeoutState(timeouts))
+}
+
+// Оценивает оставшееся время вычисления дерева по состоянию вычисления. Вычисленные узлы не учитываются,
+// у if, условие которого уже проверено, учитывается только выбранная ветка, а у остальных - самая длинная
+func EstimateProgress(tree *Node, state EstimateState) Estimate {
+	var estimate Estimate
+	durations := make(map[*Node]time.Duration)
+	var visit func(node *Node) time.Duration
+	visit = func(node *Node) time.Duration {
+		if !node.IsOperation() {
+			return 0
+		}
+		if d, ok := durations[node]; ok {
+			return d
+		}
+		remaining, done := state.Remaining(node)
+		var d time.Duration
+		if !done {
+			isIf := node.Kind == NodeFunction && node.Value == If
+			branch := 0
+			if isIf {
+				branch = state.Branch(node)
+			}
+			switch {
+			case branch != 0:
+				d = visit(node.Children[branch])
+			case isIf:
+				estimate.Operations++
+				d = visit(node.Children[0]) + remaining + maxDuration(visit(node.Children[1]), visit(node.Children[2]))
+			default:
+				estimate.Operations++
+				for _, child := range node.Children {
+					d = maxDuration(d, visit(child))
+				}
+				d += remaining
+			}
+		}
+		durations[node] = d
+		return d
+	}
+	estimate.Duration = visit(tree)
+	return estimate
+}
+
+// Состояние вычисления, которое еще не началось: каждой операции осталось ее время целиком
+type timeoutState map[string]time.Duration
+
+func (t timeoutState) Remaining(node *Node) (time.Duration, bool) {
+	return t[Operations[node.Value]], false
+}
+
+func (t timeoutState) Branch(node *Node) int {
+	return 0
+}
+
+// Возвращает большую из двух длительностей
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package rpn
+
+import (
+	"testing"
+	"time"
+)
+
+var testTimeouts = map[string]time.Duration{
+	"add": time.Second,
+	"mul": 2 * time.Second,
+	"lt":  time.Second,
+	"if":  3 * time.Second,
+	"neg": time.Second,
+}
+
+func TestEstimateDuration(t *testing.T) {
+	tests := []struct {
+		expression string
+		duration   time.Duration
+		operations int
+	}{
+		{"x", 0, 0},
+		{"1 + 2", time.Second, 1},
+		{"(1 + 2) * (3 + 4)", 3 * time.Second, 3},
+		{"1 + 2 + 3 + 4", 3 * time.Second, 3},
+		// Общее значение считается один раз
+		{"a = x * y; a + a", 3 * time.Second, 2},
+		// Условие, проверка и самая длинная ветка
+		{"if(x < 1, x * 2, x + 1)", 6 * time.Second, 4},
+		// Унарный минус - тоже операция со своим таймаутом
+		{"-(x + 1)", 2 * time.Second, 2},
+		// У операций без таймаута время нулевое
+		{"x - 1", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			got := EstimateDuration(r.Tree, testTimeouts)
+			if got.Duration != tt.duration || got.Operations != tt.operations {
+				t.Errorf("EstimateDuration() = %+v, want %s and %d operations", got, tt.duration, tt.operations)
+			}
+		})
+	}
+}
+
+// Состояние вычисления для проверки оценки: вычисленные узлы, начатые операции и выбранные ветки if
+type progressState struct {
+	done      map[*Node]bool
+	remaining map[*Node]time.Duration
+	branches  map[*Node]int
+}
+
+func (s progressState) Remaining(node *Node) (time.Duration, bool) {
+	if s.done[node] {
+		return 0, true
+	}
+	if remaining, ok := s.remaining[node]; ok {
+		return remaining, false
+	}
+	return testTimeouts[Operations[node.Value]], false
+}
+
+func (s progressState) Branch(node *Node) int {
+	return s.branches[node]
+}
+
+func TestEstimateProgress(t *testing.T) {
+	r, err := NewRPN("if(x < 1, x * 2, (x + 1) + (x + 2))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition, then, otherwise := r.Tree.Children[0], r.Tree.Children[1], r.Tree.Children[2]
+	tests := []struct {
+		name       string
+		state      progressState
+		duration   time.Duration
+		operations int
+	}{
+		{"not started", progressState{}, 6 * time.Second, 6},
+		{"condition running", progressState{remaining: map[*Node]time.Duration{condition: 500 * time.Millisecond}}, 5500 * time.Millisecond, 6},
+		{"condition done", progressState{done: map[*Node]bool{condition: true}}, 5 * time.Second, 5},
+		{"then chosen", progressState{done: map[*Node]bool{condition: true}, branches: map[*Node]int{r.Tree: 1}}, 2 * time.Second, 1},
+		{"else chosen", progressState{done: map[*Node]bool{condition: true}, branches: map[*Node]int{r.Tree: 2}}, 2 * time.Second, 3},
+		{"else half done", progressState{
+			done:     map[*Node]bool{condition: true, otherwise.Children[0]: true},
+			branches: map[*Node]int{r.Tree: 2},
+		}, 2 * time.Second, 2},
+		{"then done", progressState{done: map[*Node]bool{condition: true, then: true}, branches: map[*Node]int{r.Tree: 1}}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateProgress(r.Tree, tt.state)
+			if got.Duration != tt.duration || got.Operations != tt.operations {
+				t.Errorf("EstimateProgress() = %+v, want %s and %d operations", got, tt.duration, tt.operations)
+			}
+		})
+	}
+}
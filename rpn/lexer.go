@@ -0,0 +1,207 @@
+package rpn
+
+import "strings"
+
+// Типы токенов выражения
+type TokenType int
+
+const (
+	TokenNumber TokenType = iota
+	TokenOperator
+	TokenLeftParen
+	TokenRightParen
+	TokenIdentifier
+	TokenComma
+	TokenAssign
+	TokenSemicolon
+	// Двоеточие между именем функции и количеством аргументов в постфиксной и префиксной записи: "min:3"
+	TokenColon
+	// Имя, которое при разборе оказалось переменной, а не функцией
+	TokenVariable
+	// Квадратные скобки литерала вектора или матрицы: "[1, 2]", "[[1, 2], [3, 4]]"
+	TokenLeftBracket
+	TokenRightBracket
+)
+
+// Токен выражения: тип, текст и смещение в байтах от начала выражения
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  int
+}
+
+// Разбивает выражение на токены. Пробелы между токенами необязательны
+func Tokenize(expression string) ([]Token, error) {
+	return tokenize(expression, true)
+}
+
+// Разбивает выражение на токены. spacedUnits - может ли единица измерения отделяться от числа пробелом.
+// В постфиксной и префиксной записи операнды разделяются пробелами, поэтому там единица пишется вплотную
+// к числу ("5m"), а "2 m" - число и переменная m
+func tokenize(expression string, spacedUnits bool) ([]Token, error) {
+	var tokens []Token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case isSpace(c):
+			i++
+		case isDigit(c) || c == '.':
+			end, err := scanNumber(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			// Единица измерения после числа становится частью числа: "5 m", "9.8 m/s^2"
+			text := expression[i:end]
+			if unitEnd, unit := scanUnit(expression, end, spacedUnits); unit != "" {
+				text += " " + unit
+				end = unitEnd
+			}
+			tokens = append(tokens, Token{Type: TokenNumber, Text: text, Pos: i})
+			i = end
+		case isLetter(c):
+			end := i + 1
+			for end < len(expression) && (isLetter(expression[end]) || isDigit(expression[end])) {
+				end++
+			}
+			tokenType := TokenIdentifier
+			if _, ok := keywords[expression[i:end]]; ok {
+				tokenType = TokenOperator
+			}
+			tokens = append(tokens, Token{Type: tokenType, Text: expression[i:end], Pos: i})
+			i = end
+		case comparison(expression[i:]) != "":
+			text := comparison(expression[i:])
+			tokens = append(tokens, Token{Type: TokenOperator, Text: text, Pos: i})
+			i += len(text)
+		case strings.HasPrefix(expression[i:], "//"):
+			tokens = append(tokens, Token{Type: TokenOperator, Text: "//", Pos: i})
+			i += 2
+		case IsOperator(rune(c)):
+			tokens = append(tokens, Token{Type: TokenOperator, Text: string(c), Pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, Token{Type: TokenComma, Text: ",", Pos: i})
+			i++
+		case c == ':':
+			tokens = append(tokens, Token{Type: TokenColon, Text: ":", Pos: i})
+			i++
+		case c == ';':
+			tokens = append(tokens, Token{Type: TokenSemicolon, Text: ";", Pos: i})
+			i++
+		case c == '=':
+			tokens = append(tokens, Token{Type: TokenAssign, Text: "=", Pos: i})
+			i++
+		case c == '(':
+			tokens = append(tokens, Token{Type: TokenLeftParen, Text: "(", Pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, Token{Type: TokenRightParen, Text: ")", Pos: i})
+			i++
+		case c == '[':
+			tokens = append(tokens, Token{Type: TokenLeftBracket, Text: "[", Pos: i})
+			i++
+		case c == ']':
+			tokens = append(tokens, Token{Type: TokenRightBracket, Text: "]", Pos: i})
+			i++
+		default:
+			return nil, &ParseError{
+				Code:    ErrUnknownSymbol,
+				Message: "Неизвестный символ",
+				Token:   string([]rune(expression[i:])[0]),
+				Pos:     i,
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// Логические операции, которые записываются словами
+var keywords = map[string]struct{}{
+	And: {},
+	Or:  {},
+	Not: {},
+}
+
+// Возвращает оператор сравнения, с которого начинается строка, или пустую строку
+func comparison(s string) string {
+	for _, op := range []string{"<=", ">=", "==", "!=", "<", ">"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// Мнимая единица. Числа с ней на конце ("4i", "2.5e3i") считаются числами, а одиночное i - именем:
+// в режиме complex имя i без значения означает мнимую единицу, а в остальных режимах это обычная переменная
+const ImaginaryUnit = "i"
+
+// Считывает число, начинающееся с позиции start (целое, десятичное или в экспоненциальной записи,
+// в том числе мнимое), и возвращает позицию сразу после него
+func scanNumber(expression string, start int) (int, error) {
+	i := start
+	digits := 0
+	for i < len(expression) && isDigit(expression[i]) {
+		i++
+		digits++
+	}
+	if i < len(expression) && expression[i] == '.' {
+		i++
+		for i < len(expression) && isDigit(expression[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, invalidNumber(expression, start, i)
+	}
+	if i < len(expression) && (expression[i] == 'e' || expression[i] == 'E') {
+		j := i + 1
+		if j < len(expression) && (expression[j] == '+' || expression[j] == '-') {
+			j++
+		}
+		exponent := 0
+		for j < len(expression) && isDigit(expression[j]) {
+			j++
+			exponent++
+		}
+		if exponent == 0 {
+			return 0, invalidNumber(expression, start, j)
+		}
+		i = j
+	}
+	if i < len(expression) && (isDigit(expression[i]) || expression[i] == '.') {
+		return 0, invalidNumber(expression, start, i+1)
+	}
+	// "4i" - мнимое число, а "4if" - число и имя после него
+	if strings.HasPrefix(expression[i:], ImaginaryUnit) &&
+		(i+1 == len(expression) || !isLetter(expression[i+1]) && !isDigit(expression[i+1])) {
+		i++
+	}
+	return i, nil
+}
+
+// Создает ошибку некорректного числа, занимающего байты с start по end
+func invalidNumber(expression string, start, end int) *ParseError {
+	return &ParseError{
+		Code:    ErrInvalidNumber,
+		Message: "Некорректное число",
+		Token:   expression[start:end],
+		Pos:     start,
+	}
+}
+
+// Проверяет, является ли байт цифрой
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Проверяет, может ли байт быть частью имени (латинская буква или подчеркивание)
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+// Проверяет, является ли байт пробельным символом
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,150 @@
+package rpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeImaginaryUnit(t *testing.T) {
+	tests := []struct {
+		expression string
+		types      []TokenType
+		texts      []string
+	}{
+		{"i", []TokenType{TokenIdentifier}, []string{"i"}},
+		{"i * 2", []TokenType{TokenIdentifier, TokenOperator, TokenNumber}, []string{"i", "*", "2"}},
+		{"4i", []TokenType{TokenNumber}, []string{"4i"}},
+		{"2.5e3i", []TokenType{TokenNumber}, []string{"2.5e3i"}},
+		{"4if", []TokenType{TokenNumber, TokenIdentifier}, []string{"4", "if"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			tokens, err := Tokenize(tt.expression)
+			if err != nil {
+				t.Fatalf("Tokenize(%q): %v", tt.expression, err)
+			}
+			var types []TokenType
+			var texts []string
+			for _, token := range tokens {
+				types = append(types, token.Type)
+				texts = append(texts, token.Text)
+			}
+			if !reflect.DeepEqual(types, tt.types) || !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("Tokenize(%q) = %v %v, want %v %v", tt.expression, types, texts, tt.types, tt.texts)
+			}
+		})
+	}
+}
+
+func TestDefinitionImaginaryUnit(t *testing.T) {
+	tests := []struct {
+		source string
+		kind   NodeKind
+	}{
+		// Параметр i - обычная переменная
+		{"f(i) = i * 2", NodeVariable},
+		// i, не являющееся параметром, - мнимая единица
+		{"f(x) = x * i", NodeNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			definitions, err := ParseDefinitions([]string{tt.source})
+			if err != nil {
+				t.Fatalf("ParseDefinitions(%q): %v", tt.source, err)
+			}
+			var kind NodeKind = -1
+			definitions["f"].Body.Walk(func(node *Node) error {
+				if node.Value == ImaginaryUnit {
+					kind = node.Kind
+				}
+				return nil
+			})
+			if kind != tt.kind {
+				t.Errorf("kind of i = %s, want %s", kind, tt.kind)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		expression string
+		texts      []string
+		positions  []int
+	}{
+		{"2+3", []string{"2", "+", "3"}, []int{0, 1, 2}},
+		{"  12 *\t3.5 ", []string{"12", "*", "3.5"}, []int{2, 5, 7}},
+		{".5-1e3", []string{".5", "-", "1e3"}, []int{0, 2, 3}},
+		{"7//2%3^2", []string{"7", "//", "2", "%", "3", "^", "2"}, []int{0, 1, 3, 4, 5, 6, 7}},
+		{"a<=b!=c", []string{"a", "<=", "b", "!=", "c"}, []int{0, 1, 3, 4, 6}},
+		{"not a and b", []string{"not", "a", "and", "b"}, []int{0, 4, 6, 10}},
+		{"min(a, 2)", []string{"min", "(", "a", ",", "2", ")"}, []int{0, 3, 4, 5, 7, 8}},
+		{"x = 1; x", []string{"x", "=", "1", ";", "x"}, []int{0, 2, 4, 5, 7}},
+		{"[1, 2]", []string{"[", "1", ",", "2", "]"}, []int{0, 1, 2, 4, 5}},
+		{"min:3", []string{"min", ":", "3"}, []int{0, 3, 4}},
+		{"5 m + 2km", []string{"5 m", "+", "2 km"}, []int{0, 4, 6}},
+		{"2.5E-3/(4)", []string{"2.5E-3", "/", "(", "4", ")"}, []int{0, 6, 7, 8, 9}},
+		{"", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			tokens, err := Tokenize(tt.expression)
+			if err != nil {
+				t.Fatalf("Tokenize(%q): %v", tt.expression, err)
+			}
+			var texts []string
+			var positions []int
+			for _, token := range tokens {
+				texts = append(texts, token.Text)
+				positions = append(positions, token.Pos)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) || !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("Tokenize(%q) = %q %v, want %q %v", tt.expression, texts, positions, tt.texts, tt.positions)
+			}
+		})
+	}
+}
+
+func TestTokenizeTypes(t *testing.T) {
+	tokens, err := Tokenize("(1 - 2)*3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TokenType{TokenLeftParen, TokenNumber, TokenOperator, TokenNumber, TokenRightParen, TokenOperator, TokenNumber}
+	var got []TokenType
+	for _, token := range tokens {
+		got = append(got, token.Type)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("types = %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		code       string
+		token      string
+		pos        int
+	}{
+		{"2 $ 3", ErrUnknownSymbol, "$", 2},
+		{"1 + ж", ErrUnknownSymbol, "ж", 4},
+		{"1.2.3", ErrInvalidNumber, "1.2.", 0},
+		{"2 + .", ErrInvalidNumber, ".", 4},
+		{"1e+", ErrInvalidNumber, "1e+", 0},
+		{"3ex", ErrInvalidNumber, "3e", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Tokenize(tt.expression)
+			parseErr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("Tokenize(%q) error = %v, want *ParseError", tt.expression, err)
+			}
+			if parseErr.Code != tt.code || parseErr.Token != tt.token || parseErr.Pos != tt.pos {
+				t.Errorf("Tokenize(%q) error = %s %q at %d, want %s %q at %d",
+					tt.expression, parseErr.Code, parseErr.Token, parseErr.Pos, tt.code, tt.token, tt.pos)
+			}
+		})
+	}
+}
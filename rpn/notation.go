@@ -0,0 +1,230 @@
+package rpn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Записи выражения
+const (
+	NotationInfix  = "infix"
+	NotationRPN    = "rpn"
+	NotationPrefix = "prefix"
+)
+
+// Возвращает названия всех поддерживаемых записей выражения
+func Notations() []string {
+	return []string{NotationInfix, NotationRPN, NotationPrefix}
+}
+
+// Проверяет, поддерживается ли запись выражения. Пустая запись означает обычную
+func IsNotation(notation string) bool {
+	switch notation {
+	case "", NotationInfix, NotationRPN, NotationPrefix:
+		return true
+	}
+	return false
+}
+
+// Элемент постфиксной или префиксной записи: число, имя, оператор или функция.
+// У функции arity - количество аргументов, указанное через двоеточие, или -1, если оно не указано
+type stackItem struct {
+	token Token
+	arity int
+}
+
+// Поддерево, лежащее в стеке при разборе, и его запись в обратной польской нотации
+type stackEntry struct {
+	node  *Node
+	rpn   string
+	token Token
+}
+
+// Разбирает выражение в постфиксной ("2 3 4 * +") или префиксной ("+ 2 * 3 4") записи. Унарный минус
+// записывается как "neg", число аргументов функции с переменным числом аргументов - через двоеточие: "min:3".
+// Проверяет, что каждой операции хватает операндов и что в конце в стеке остается ровно одно значение
+func (r *RPN) convertFromStackNotation() error {
+	tokens, err := tokenize(r.SNExpression, false)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return &ParseError{Code: ErrEmptyExpression, Message: "Пустое выражение", Pos: 0}
+	}
+	items, err := stackItems(tokens)
+	if err != nil {
+		return err
+	}
+
+	// Префиксная запись разбирается справа налево, тогда первый операнд оказывается на вершине стека
+	prefix := r.options.Notation == NotationPrefix
+	if prefix {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	var stack []stackEntry
+	for _, item := range items {
+		arity, err := r.itemArity(item)
+		if err != nil {
+			return err
+		}
+		if len(stack) < arity {
+			return newParseError(
+				ErrMissingOperand,
+				fmt.Sprintf("Операции не хватает операндов: нужно %d, в стеке %d", arity, len(stack)),
+				item.token,
+			)
+		}
+		operands := make([]stackEntry, arity)
+		copy(operands, stack[len(stack)-arity:])
+		stack = stack[:len(stack)-arity]
+		if prefix {
+			for i, j := 0, len(operands)-1; i < j; i, j = i+1, j-1 {
+				operands[i], operands[j] = operands[j], operands[i]
+			}
+		}
+		stack = append(stack, r.combine(item, operands))
+	}
+	if len(stack) > 1 {
+		return newParseError(ErrExtraOperand, "Лишний операнд: для него не хватает операции", stack[0].token)
+	}
+
+	r.Tree = stack[0].node
+	r.RPNExpression = stack[0].rpn
+	r.SNExpression = Infix(r.Tree)
+	return nil
+}
+
+// Собирает элементы записи из токенов: склеивает имя функции с количеством аргументов
+// и минус, стоящий вплотную перед числом, с этим числом
+func stackItems(tokens []Token) ([]stackItem, error) {
+	var items []stackItem
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.Type {
+		case TokenNumber:
+			items = append(items, stackItem{token: token})
+		case TokenOperator:
+			separated := i == 0 || tokens[i-1].Pos+len(tokens[i-1].Text) < token.Pos
+			if token.Text == "-" && separated && i+1 < len(tokens) &&
+				tokens[i+1].Type == TokenNumber && tokens[i+1].Pos == token.Pos+1 {
+				i++
+				number := Token{Type: TokenNumber, Text: "-" + tokens[i].Text, Pos: token.Pos}
+				items = append(items, stackItem{token: number})
+				continue
+			}
+			items = append(items, stackItem{token: token})
+		case TokenIdentifier:
+			item := stackItem{token: token, arity: -1}
+			if i+1 < len(tokens) && tokens[i+1].Type == TokenColon {
+				if i+2 >= len(tokens) || tokens[i+2].Type != TokenNumber {
+					return nil, newParseError(ErrInvalidArity, "После двоеточия ожидалось количество аргументов", tokens[i+1])
+				}
+				arity, err := strconv.Atoi(tokens[i+2].Text)
+				if err != nil {
+					return nil, newParseError(ErrInvalidArity, "Некорректное количество аргументов", tokens[i+2])
+				}
+				item.arity = arity
+				i += 2
+			}
+			items = append(items, item)
+		default:
+			return nil, newParseError(
+				ErrMisplacedOperator,
+				"Скобки, запятые и присваивания недопустимы в постфиксной и префиксной записи",
+				token,
+			)
+		}
+	}
+	return items, nil
+}
+
+// Возвращает количество операндов, которые элемент забирает из стека
+func (r *RPN) itemArity(item stackItem) (int, error) {
+	token := item.token
+	switch token.Type {
+	case TokenNumber:
+		return 0, nil
+	case TokenOperator:
+		if token.Text == Not {
+			return 1, nil
+		}
+		return 2, nil
+	}
+
+	if token.Text == Negation && item.arity == -1 {
+		return 1, nil
+	}
+	spec, ok := r.function(token.Text)
+	switch {
+	case !ok && item.arity != -1:
+		return 0, newParseError(ErrUnknownIdentifier, "Неизвестная функция", token)
+	case !ok:
+		return 0, nil
+	case item.arity == -1 && spec.IsVariadic():
+		return 0, newParseError(
+			ErrInvalidArity,
+			fmt.Sprintf("Укажите количество аргументов функции через двоеточие: %s:%d", token.Text, spec.MinArgs),
+			token,
+		)
+	case item.arity == -1:
+		return spec.MinArgs, nil
+	case !spec.Accepts(item.arity):
+		return 0, newParseError(
+			ErrInvalidArity,
+			fmt.Sprintf("Неверное количество аргументов функции: %d", item.arity),
+			token,
+		)
+	}
+	return item.arity, nil
+}
+
+// Строит узел элемента из операндов, снятых со стека
+func (r *RPN) combine(item stackItem, operands []stackEntry) stackEntry {
+	token := item.token
+	children := make([]*Node, len(operands))
+	texts := make([]string, 0, len(operands)+1)
+	for i, operand := range operands {
+		children[i] = operand.node
+		texts = append(texts, operand.rpn)
+	}
+	entry := stackEntry{token: token}
+	node := &Node{Value: token.Text, Children: children, Pos: token.Pos}
+	text := token.Text
+
+	switch {
+	case token.Type == TokenNumber:
+		node.Kind = NodeNumber
+	case token.Type == TokenOperator && token.Text == Not:
+		node.Kind = NodeUnary
+	case token.Type == TokenOperator:
+		node.Kind = NodeBinary
+	case len(operands) == 0:
+		node.Kind = NodeVariable
+	case token.Text == Negation:
+		if operand := children[0]; operand.Kind == NodeNumber {
+			// Как и в обычной записи, минус перед числом становится частью числа
+			value := Negate(operand.Value)
+			entry.node = &Node{Kind: NodeNumber, Value: value, Pos: token.Pos}
+			entry.rpn = value
+			return entry
+		}
+		node.Kind = NodeUnary
+	default:
+		if definition, ok := r.options.Functions[token.Text]; ok {
+			entry.node = definition.instantiate(children, token.Pos)
+			entry.rpn = strings.Join(append(texts, text), " ")
+			return entry
+		}
+		node.Kind = NodeFunction
+		if Functions[token.Text].IsVariadic() {
+			text = fmt.Sprintf("%s:%d", token.Text, len(operands))
+		}
+	}
+	entry.node = node
+	entry.rpn = strings.Join(append(texts, text), " ")
+	return entry
+}
@@ -0,0 +1,93 @@
+package rpn
+
+import "testing"
+
+func TestStackNotationUnits(t *testing.T) {
+	tests := []struct {
+		notation   string
+		expression string
+		infix      string
+		variables  int
+	}{
+		{NotationRPN, "2 m *", "2 * m", 1},
+		{NotationRPN, "2 s * 3 +", "2 * s + 3", 1},
+		{NotationPrefix, "* 2 m", "2 * m", 1},
+		{NotationRPN, "5m 2 *", "5 m * 2", 0},
+		{NotationRPN, "9.8m/s^2 2 *", "9.8 m/s^2 * 2", 0},
+		{NotationInfix, "2 m * 3", "2 m * 3", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation+" "+tt.expression, func(t *testing.T) {
+			r, err := Parse(tt.expression, Options{Notation: tt.notation})
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expression, err)
+			}
+			if got := Infix(r.Tree); got != tt.infix {
+				t.Errorf("Infix() = %q, want %q", got, tt.infix)
+			}
+			if got := len(r.Tree.Variables()); got != tt.variables {
+				t.Errorf("len(Variables()) = %d, want %d", got, tt.variables)
+			}
+		})
+	}
+}
+
+func TestStackNotation(t *testing.T) {
+	tests := []struct {
+		notation   string
+		expression string
+		infix      string
+		rpn        string
+	}{
+		{NotationRPN, "2 3 4 * +", "2 + 3 * 4", "2 3 4 * +"},
+		{NotationPrefix, "+ 2 * 3 4", "2 + 3 * 4", "2 3 4 * +"},
+		{NotationRPN, "2 3 + 4 *", "(2 + 3) * 4", "2 3 + 4 *"},
+		{NotationPrefix, "- 10 - 4 3", "10 - (4 - 3)", "10 4 3 - -"},
+		{NotationRPN, "x neg", "-x", "x neg"},
+		{NotationRPN, "-2 3 -", "-2 - 3", "-2 3 -"},
+		{NotationRPN, "1 2 3 min:3 abs", "abs(min(1, 2, 3))", "1 2 3 min:3 abs"},
+		{NotationPrefix, "max:2 x sqrt y", "max(x, sqrt(y))", "x y sqrt max:2"},
+		{NotationRPN, "a b < c not and", "a < b and not c", "a b < c not and"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation+" "+tt.expression, func(t *testing.T) {
+			r, err := Parse(tt.expression, Options{Notation: tt.notation})
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expression, err)
+			}
+			if r.SNExpression != tt.infix {
+				t.Errorf("SNExpression = %q, want %q", r.SNExpression, tt.infix)
+			}
+			if r.RPNExpression != tt.rpn {
+				t.Errorf("RPNExpression = %q, want %q", r.RPNExpression, tt.rpn)
+			}
+		})
+	}
+}
+
+func TestStackNotationErrors(t *testing.T) {
+	tests := []struct {
+		notation   string
+		expression string
+		code       string
+	}{
+		{NotationRPN, "", ErrEmptyExpression},
+		{NotationRPN, "2 +", ErrMissingOperand},
+		{NotationPrefix, "+ 2", ErrMissingOperand},
+		{NotationRPN, "2 3", ErrExtraOperand},
+		{NotationRPN, "(2 3 +)", ErrMisplacedOperator},
+		{NotationRPN, "1 2 min", ErrInvalidArity},
+		{NotationRPN, "1 abs:2", ErrInvalidArity},
+		{NotationRPN, "1 2 foo:2", ErrUnknownIdentifier},
+		{"postfix", "2 3 +", ErrUnknownNotation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation+" "+tt.expression, func(t *testing.T) {
+			_, err := Parse(tt.expression, Options{Notation: tt.notation})
+			parseErr, ok := err.(*ParseError)
+			if !ok || parseErr.Code != tt.code {
+				t.Errorf("Parse(%q) error = %v, want code %s", tt.expression, err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package rpn
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Настройки оптимизации дерева выражения
+type OptimizeOptions struct {
+	// Вычисляет операцию над числами в режиме вычислений выражения
+	Calculate func(operation string, operands []string) (string, error)
+	// В режиме вычислений нет бесконечностей и NaN, поэтому x*0 можно заменить нулем
+	Exact bool
+	// Ни результат сложения и умножения, ни ошибка не зависят от порядка операций,
+	// поэтому операнды их цепочек можно переставлять
+	Associative bool
+	// Проверяет, что значение переменной - безразмерное число, а не вектор, матрица или величина с единицей
+	// измерения. Если не задана, значения переменных считаются неизвестными
+	Scalar func(name string) bool
+}
+
+// Оптимизатор дерева выражения
+type optimizer struct {
+	options OptimizeOptions
+	// Узлы, про которые уже известно, безразмерные ли они числа
+	scalars map[*Node]bool
+}
+
+// Оптимизирует дерево выражения перед отправкой агенту: вычисляет подвыражения из одних чисел,
+// упрощает x+0, x-0, x*1, x/1, x^1 и x*0, объединяет одинаковые подвыражения в один узел
+// и перестраивает цепочки + и * в сбалансированные деревья, чтобы их операции считались параллельно.
+// Подвыражения, вычисление которых заканчивается ошибкой, остаются агенту. Исходное дерево не меняется
+func Optimize(tree *Node, options OptimizeOptions) *Node {
+	o := &optimizer{options: options, scalars: make(map[*Node]bool)}
+	tree = dedupe(o.simplify(tree))
+	if options.Associative {
+		tree = dedupe(o.simplify(o.rebalance(tree)))
+	}
+	return tree
+}
+
+// Возвращает длину критического пути: наибольшее количество операций, которые придется выполнить
+// одну за другой. У if учитывается самая длинная ветка
+func CriticalPath(tree *Node) int {
+	return depth(tree, make(map[*Node]int))
+}
+
+// Считает длину критического пути узла, запоминая результаты для общих узлов
+func depth(node *Node, memo map[*Node]int) int {
+	if !node.IsOperation() {
+		return 0
+	}
+	if d, ok := memo[node]; ok {
+		return d
+	}
+	d := 0
+	for _, child := range node.Children {
+		if childDepth := depth(child, memo); childDepth > d {
+			d = childDepth
+		}
+	}
+	memo[node] = d + 1
+	return d + 1
+}
+
+// Упрощает дерево снизу вверх
+func (o *optimizer) simplify(tree *Node) *Node {
+	memo := make(map[*Node]*Node)
+	var visit func(node *Node) *Node
+	visit = func(node *Node) *Node {
+		if res, ok := memo[node]; ok {
+			return res
+		}
+		res := node
+		if node.IsOperation() {
+			children := make([]*Node, len(node.Children))
+			for i, child := range node.Children {
+				children[i] = visit(child)
+			}
+			res = o.simplifyOperation(&Node{Kind: node.Kind, Value: node.Value, Children: children, Pos: node.Pos})
+		}
+		memo[node] = res
+		return res
+	}
+	return visit(tree)
+}
+
+// Упрощает операцию, операнды которой уже упрощены
+func (o *optimizer) simplifyOperation(node *Node) *Node {
+	if node.Kind == NodeFunction && node.Value == If {
+		// Если условие известно заранее, остается только нужная ветка
+		condition := node.Children[0]
+		if condition.Kind == NodeNumber {
+			if o.test("!=", condition.Value, "0") {
+				return node.Children[1]
+			}
+			if o.test("==", condition.Value, "0") {
+				return node.Children[2]
+			}
+		}
+		return node
+	}
+	if allNumbers(node.Children) {
+		if folded, ok := o.fold(node.Value, node.Children, node.Pos); ok {
+			return folded
+		}
+		return node
+	}
+	if node.Kind != NodeBinary {
+		return node
+	}
+
+	// Тождества упрощаются, только если x - безразмерное число: для вектора x*0 - вектор из нулей,
+	// а 5 m + 0 - ошибка несовместимых единиц, которую нельзя терять
+	left, right := node.Children[0], node.Children[1]
+	switch node.Value {
+	case "+":
+		if o.isValue(right, "0") && o.isScalar(left) {
+			return left
+		}
+		if o.isValue(left, "0") && o.isScalar(right) {
+			return right
+		}
+	case "-":
+		if o.isValue(right, "0") && o.isScalar(left) {
+			return left
+		}
+	case "*":
+		if o.isValue(right, "1") && o.isScalar(left) {
+			return left
+		}
+		if o.isValue(left, "1") && o.isScalar(right) {
+			return right
+		}
+		// x*0 упрощается, только если x не может закончиться ошибкой и не может оказаться бесконечностью
+		if o.options.Exact && o.isValue(right, "0") && !left.IsOperation() && o.isScalar(left) {
+			return right
+		}
+		if o.options.Exact && o.isValue(left, "0") && !right.IsOperation() && o.isScalar(right) {
+			return left
+		}
+	case "/", "^":
+		if o.isValue(right, "1") && o.isScalar(left) {
+			return left
+		}
+	}
+	return node
+}
+
+// Проверяет, что значением узла точно будет безразмерное число, а не вектор, матрица или величина
+// с единицей измерения. Результат операции над такими числами - тоже такое число, кроме функций,
+// которые строят векторы или переводят единицы
+func (o *optimizer) isScalar(node *Node) bool {
+	if scalar, ok := o.scalars[node]; ok {
+		return scalar
+	}
+	var scalar bool
+	switch node.Kind {
+	case NodeNumber:
+		_, unit, err := SplitQuantity(node.Value)
+		scalar = err == nil && unit.Empty()
+	case NodeVariable:
+		scalar = o.options.Scalar != nil && o.options.Scalar(node.Value)
+	case NodeFunction:
+		if _, ok := scalarFunctions[node.Value]; !ok {
+			break
+		}
+		fallthrough
+	default:
+		scalar = true
+		for i, child := range node.Children {
+			// Условие if не становится результатом
+			if node.Value == If && i == 0 {
+				continue
+			}
+			if !o.isScalar(child) {
+				scalar = false
+				break
+			}
+		}
+	}
+	o.scalars[node] = scalar
+	return scalar
+}
+
+// Функции, которые от безразмерных чисел возвращают безразмерное число
+var scalarFunctions = map[string]struct{}{
+	If:      {},
+	"abs":   {},
+	"sqrt":  {},
+	"min":   {},
+	"max":   {},
+	"round": {},
+	"conj":  {},
+	"re":    {},
+	"im":    {},
+}
+
+// Перестраивает цепочки + и * так, чтобы длина их критического пути была наименьшей: каждый раз объединяются
+// два операнда с самыми короткими критическими путями. Одинаковые операнды складываются в дерево глубины log2(n),
+// а длинные подвыражения начинают считаться сразу и не ждут остальных. Числа в цепочке сразу складываются
+// или перемножаются. Общие узлы не разбираются, чтобы не считать их дважды
+func (o *optimizer) rebalance(tree *Node) *Node {
+	parents := countParents(tree)
+	depths := make(map[*Node]int)
+	memo := make(map[*Node]*Node)
+	var visit func(node *Node) *Node
+	visit = func(node *Node) *Node {
+		if res, ok := memo[node]; ok {
+			return res
+		}
+		if !node.IsOperation() {
+			return node
+		}
+
+		var operands []*Node
+		if isChain(node) {
+			var collect func(n *Node)
+			collect = func(n *Node) {
+				for _, child := range n.Children {
+					if isChain(child) && child.Value == node.Value && parents[child] == 1 {
+						collect(child)
+					} else {
+						operands = append(operands, visit(child))
+					}
+				}
+			}
+			collect(node)
+		} else {
+			for _, child := range node.Children {
+				operands = append(operands, visit(child))
+			}
+			res := &Node{Kind: node.Kind, Value: node.Value, Children: operands, Pos: node.Pos}
+			memo[node] = res
+			return res
+		}
+
+		operands = o.foldNumbers(node.Value, operands, node.Pos)
+		sort.SliceStable(operands, func(i, j int) bool {
+			return depth(operands[i], depths) < depth(operands[j], depths)
+		})
+		for len(operands) > 1 {
+			combined := &Node{Kind: NodeBinary, Value: node.Value, Children: []*Node{operands[0], operands[1]}, Pos: node.Pos}
+			operands = operands[2:]
+			// Новый узел встает перед первым операндом с более длинным критическим путем
+			i := sort.Search(len(operands), func(i int) bool {
+				return depth(operands[i], depths) > depth(combined, depths)
+			})
+			operands = append(operands[:i], append([]*Node{combined}, operands[i:]...)...)
+		}
+		memo[node] = operands[0]
+		return operands[0]
+	}
+	return visit(tree)
+}
+
+// Заменяет все числа среди операндов цепочки одним числом, если их удалось вычислить
+func (o *optimizer) foldNumbers(operation string, operands []*Node, pos int) []*Node {
+	var numbers, rest []*Node
+	for _, operand := range operands {
+		if operand.Kind == NodeNumber {
+			numbers = append(numbers, operand)
+		} else {
+			rest = append(rest, operand)
+		}
+	}
+	if len(numbers) < 2 {
+		return operands
+	}
+	acc := numbers[0]
+	for _, number := range numbers[1:] {
+		folded, ok := o.fold(operation, []*Node{acc, number}, pos)
+		if !ok {
+			return operands
+		}
+		acc = folded
+	}
+	return append(rest, acc)
+}
+
+// Вычисляет операцию над числами. Результат подходит, только если его можно записать числом в выражении
+func (o *optimizer) fold(operation string, operands []*Node, pos int) (*Node, bool) {
+	values := make([]string, len(operands))
+	for i, operand := range operands {
+		values[i] = operand.Value
+	}
+	res, err := o.options.Calculate(operation, values)
+	if err != nil || !isLiteral(res) {
+		return nil, false
+	}
+	return &Node{Kind: NodeNumber, Value: res, Pos: pos}, true
+}
+
+// Проверяет, что сравнение двух чисел истинно
+func (o *optimizer) test(operation, a, b string) bool {
+	res, err := o.options.Calculate(operation, []string{a, b})
+	return err == nil && res == "1"
+}
+
+// Проверяет, что узел является числом, равным value
+func (o *optimizer) isValue(node *Node, value string) bool {
+	return node.Kind == NodeNumber && o.test("==", node.Value, value)
+}
+
+// Проверяет, что строка записывается в выражении одним числом, возможно со знаком минус
+func isLiteral(s string) bool {
+	unsigned := s
+	if len(s) > 0 && s[0] == '-' {
+		unsigned = s[1:]
+	}
+	tokens, err := Tokenize(unsigned)
+	return err == nil && len(tokens) == 1 && tokens[0].Type == TokenNumber && tokens[0].Text == unsigned
+}
+
+// Проверяет, что все узлы являются числами
+func allNumbers(nodes []*Node) bool {
+	for _, node := range nodes {
+		if node.Kind != NodeNumber {
+			return false
+		}
+	}
+	return len(nodes) > 0
+}
+
+// Проверяет, является ли узел звеном цепочки сложений или умножений
+func isChain(node *Node) bool {
+	return node.Kind == NodeBinary && (node.Value == "+" || node.Value == "*")
+}
+
+// Проверяет, не зависит ли результат операции от порядка операндов
+func isCommutative(node *Node) bool {
+	switch node.Value {
+	case "+", "*", "==", "!=", And, Or:
+		return node.Kind == NodeBinary
+	case "min", "max":
+		return node.Kind == NodeFunction
+	}
+	return false
+}
+
+// Считает, сколько операций ссылается на каждый узел
+func countParents(tree *Node) map[*Node]int {
+	parents := make(map[*Node]int)
+	tree.Walk(func(node *Node) error {
+		for _, child := range node.Children {
+			parents[child]++
+		}
+		return nil
+	})
+	return parents
+}
+
+// Объединяет одинаковые подвыражения в один узел, чтобы агент вычислил их один раз.
+// Операнды коммутативных операций упорядочиваются, поэтому a*b и b*a тоже считаются одинаковыми
+func dedupe(tree *Node) *Node {
+	ids := make(map[*Node]int)
+	canonical := make(map[string]*Node)
+	memo := make(map[*Node]*Node)
+	var visit func(node *Node) *Node
+	visit = func(node *Node) *Node {
+		if res, ok := memo[node]; ok {
+			return res
+		}
+		children := make([]*Node, len(node.Children))
+		for i, child := range node.Children {
+			children[i] = visit(child)
+		}
+		if isCommutative(node) {
+			sort.SliceStable(children, func(i, j int) bool {
+				return ids[children[i]] < ids[children[j]]
+			})
+		}
+		key := fmt.Sprintf("%d %s", node.Kind, node.Value)
+		for _, child := range children {
+			key += fmt.Sprintf(" %d", ids[child])
+		}
+		res, ok := canonical[key]
+		if !ok {
+			res = &Node{Kind: node.Kind, Value: node.Value, Children: children, Pos: node.Pos}
+			canonical[key] = res
+			ids[res] = len(ids)
+		}
+		memo[node] = res
+		return res
+	}
+	return visit(tree)
+}
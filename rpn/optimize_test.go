@@ -0,0 +1,160 @@
+package rpn_test
+
+import (
+	"testing"
+
+	"github.com/oleg-top/go-orchestrator/numeric"
+	"github.com/oleg-top/go-orchestrator/rpn"
+)
+
+// Оптимизирует выражение так же, как оркестратор
+func optimize(t *testing.T, mode, expression string, variables map[string]string) *rpn.Node {
+	t.Helper()
+	r, err := rpn.NewRPN(expression)
+	if err != nil {
+		t.Fatalf("NewRPN(%q): %v", expression, err)
+	}
+	return rpn.Optimize(r.Tree, rpn.OptimizeOptions{
+		Calculate: func(operation string, operands []string) (string, error) {
+			return numeric.Calculate(mode, operation, operands)
+		},
+		Exact:       numeric.IsExact(mode),
+		Associative: numeric.IsAssociative(mode),
+		Scalar: func(name string) bool {
+			value, ok := variables[name]
+			return ok && numeric.IsScalar(value)
+		},
+	})
+}
+
+// Вычисляет дерево выражения по порядку, как агент
+func evaluate(mode string, node *rpn.Node, variables map[string]string) (string, error) {
+	switch node.Kind {
+	case rpn.NodeNumber:
+		return node.Value, nil
+	case rpn.NodeVariable:
+		return variables[node.Value], nil
+	}
+	operands := make([]string, len(node.Children))
+	for i, child := range node.Children {
+		value, err := evaluate(mode, child, variables)
+		if err != nil {
+			return "", err
+		}
+		operands[i] = value
+	}
+	return numeric.Calculate(mode, node.Value, operands)
+}
+
+func TestOptimize(t *testing.T) {
+	scalars := map[string]string{"x": "5", "y": "2", "a": "1", "b": "2", "c": "3", "d": "4"}
+	tests := []struct {
+		name       string
+		mode       string
+		expression string
+		variables  map[string]string
+		want       string
+	}{
+		{"fold", numeric.ModeInt64, "2 * 3 + x", scalars, "6 + x"},
+		{"fold error stays", numeric.ModeInt64, "1 / 0 + x", scalars, "1 / 0 + x"},
+		{"if known", numeric.ModeInt64, "if(1 < 2, x, y)", scalars, "x"},
+		{"x+0", numeric.ModeInt64, "x + 0", scalars, "x"},
+		{"0+x", numeric.ModeInt64, "0 + x", scalars, "x"},
+		{"x-0", numeric.ModeInt64, "x - 0", scalars, "x"},
+		{"x*1", numeric.ModeInt64, "x * 1", scalars, "x"},
+		{"x/1", numeric.ModeFloat64, "x / 1", scalars, "x"},
+		{"x^1", numeric.ModeInt64, "abs(x) ^ 1", scalars, "abs(x)"},
+		{"x*0", numeric.ModeInt64, "x * 0", scalars, "0"},
+		{"0*x", numeric.ModeDecimal, "0 * x", scalars, "0"},
+		{"x*0 float", numeric.ModeFloat64, "x * 0", scalars, "x * 0"},
+		{"x*0 operation", numeric.ModeInt64, "(x + y) * 0", scalars, "(x + y) * 0"},
+		{"unit x+0", numeric.ModeDecimal, "x + 0", map[string]string{"x": "5 m"}, "x + 0"},
+		{"unit literal", numeric.ModeDecimal, "5 m * 1", nil, "5 m"},
+		{"vector x*0", numeric.ModeInt64, "x * 0", map[string]string{"x": "[1, 2]"}, "x * 0"},
+		{"vector x*1", numeric.ModeInt64, "x * 1", map[string]string{"x": "[1, 2]"}, "x * 1"},
+		{"unknown variable", numeric.ModeInt64, "x * 1", nil, "x * 1"},
+		{"conversion", numeric.ModeDecimal, "to(x, km) + 0", scalars, "0 + to(x, 1 km)"},
+		{"dedupe", numeric.ModeFloat64, "x * y + y * x", scalars, "_1 = x * y; _1 + _1"},
+		{"rebalance", numeric.ModeDecimal, "a + b + c + d", scalars, "a + b + (c + d)"},
+		{"rebalance long operand", numeric.ModeDecimal, "a + b * c + d", scalars, "b * c + (a + d)"},
+		{"rebalance fold", numeric.ModeBigInt, "1 + a + 2 + b", scalars, "3 + (a + b)"},
+		{"int64 keeps order", numeric.ModeInt64, "a + b + c + d", scalars, "a + b + c + d"},
+		{"float keeps order", numeric.ModeFloat64, "a + b + c + d", scalars, "a + b + c + d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rpn.Infix(optimize(t, tt.mode, tt.expression, tt.variables))
+			if got != tt.want {
+				t.Errorf("Optimize(%q) = %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+// Оптимизация не должна менять ни результат, ни ошибку: в int64 переполнение промежуточного результата
+// зависит от порядка операций
+func TestOptimizeKeepsResult(t *testing.T) {
+	const (
+		max    = "9223372036854775807"
+		negMax = "-9223372036854775807"
+	)
+	tests := []struct {
+		mode       string
+		expression string
+		variables  map[string]string
+	}{
+		{numeric.ModeInt64, "x + 1 + 2 + y", map[string]string{"x": max, "y": "-10"}},
+		{numeric.ModeInt64, "a + b + c * d", map[string]string{"a": max, "b": "-10", "c": "1", "d": "10"}},
+		{numeric.ModeInt64, "a + b + c + d", map[string]string{"a": negMax, "b": "0", "c": max, "d": max}},
+		{numeric.ModeBigInt, "x + 1 + 2 + y", map[string]string{"x": max, "y": "-10"}},
+		{numeric.ModeDecimal, "a * 3 + b + 1 + c", map[string]string{"a": "1/3", "b": "0.5", "c": "-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.expression, func(t *testing.T) {
+			r, err := rpn.NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			want, wantErr := evaluate(tt.mode, r.Tree, tt.variables)
+			tree := optimize(t, tt.mode, tt.expression, tt.variables)
+			got, err := evaluate(tt.mode, tree, tt.variables)
+			if got != want || (err == nil) != (wantErr == nil) {
+				t.Errorf("%s = %q, %v, want %q, %v", rpn.Infix(tree), got, err, want, wantErr)
+			}
+		})
+	}
+}
+
+// Оптимизация не должна менять результат: выражение с величиной, которую нельзя сложить с нулем,
+// после оптимизации заканчивается той же ошибкой
+func TestOptimizeKeepsUnitError(t *testing.T) {
+	tree := optimize(t, numeric.ModeDecimal, "x + 0", map[string]string{"x": "5 m"})
+	if tree.Kind != rpn.NodeBinary {
+		t.Fatalf("Optimize(x + 0) = %q, want the addition to stay", rpn.Infix(tree))
+	}
+	_, err := numeric.Calculate(numeric.ModeDecimal, tree.Value, []string{"5 m", tree.Children[1].Value})
+	if err == nil {
+		t.Error("Calculate(5 m + 0) succeeded, want incompatible units error")
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{numeric.ModeDecimal, 3},
+		{numeric.ModeBigInt, 3},
+		{numeric.ModeInt64, 7},
+		{numeric.ModeFloat64, 7},
+	}
+	scalars := map[string]string{"a": "1", "b": "1", "c": "1", "d": "1", "e": "1", "f": "1", "g": "1", "h": "1"}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			tree := optimize(t, tt.mode, "a + b + c + d + e + f + g + h", scalars)
+			if got := rpn.CriticalPath(tree); got != tt.want {
+				t.Errorf("CriticalPath() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
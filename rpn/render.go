@@ -0,0 +1,296 @@
+package rpn
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Записывает дерево выражения в обычной нотации с минимумом скобок. Операции, на которые ссылаются
+// несколько узлов, выносятся в отдельные инструкции "_1 = ...", поэтому при разборе записи снова
+// получается тот же граф
+func Infix(tree *Node) string {
+	names, order := bindShared(tree)
+	statements := make([]string, 0, len(order)+1)
+	for _, node := range order {
+		statements = append(statements, names[node]+" = "+renderInfix(node, names))
+	}
+	return strings.Join(append(statements, renderInfix(tree, names)), "; ")
+}
+
+// Записывает дерево выражения в LaTeX. Общие операции, как и в Infix, выносятся в отдельные строки
+func Latex(tree *Node) string {
+	names, order := bindShared(tree)
+	lines := make([]string, 0, len(order)+1)
+	for _, node := range order {
+		lines = append(lines, latexName(names[node])+" = "+renderLatex(node, names))
+	}
+	return strings.Join(append(lines, renderLatex(tree, names)), ",\\quad ")
+}
+
+// Дает имена операциям, на которые ссылаются несколько узлов, и возвращает их в порядке вычисления:
+// каждая операция идет после всех операций, от которых она зависит. Имена не совпадают с переменными дерева
+func bindShared(tree *Node) (map[*Node]string, []*Node) {
+	parents := countParents(tree)
+	used := make(map[string]bool)
+	for _, variable := range tree.Variables() {
+		used[variable.Value] = true
+	}
+
+	names := make(map[*Node]string)
+	var order []*Node
+	visited := make(map[*Node]bool)
+	counter := 0
+	var visit func(node *Node)
+	visit = func(node *Node) {
+		if visited[node] {
+			return
+		}
+		visited[node] = true
+		for _, child := range node.Children {
+			visit(child)
+		}
+		if node.IsOperation() && parents[node] > 1 {
+			name := ""
+			for name == "" || used[name] {
+				counter++
+				name = "_" + strconv.Itoa(counter)
+			}
+			names[node] = name
+			order = append(order, node)
+		}
+	}
+	visit(tree)
+	return names, order
+}
+
+// Записывает узел в обычной нотации. Операнды, у которых есть имя, записываются именем
+func renderInfix(node *Node, names map[*Node]string) string {
+	operand := func(child *Node) string {
+		if name, ok := names[child]; ok {
+			return name
+		}
+		return renderInfix(child, names)
+	}
+	switch node.Kind {
+	case NodeFunction:
+		args := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			args[i] = operand(child)
+		}
+		if node.Value == Vector {
+			return "[" + strings.Join(args, ", ") + "]"
+		}
+		return node.Value + "(" + strings.Join(args, ", ") + ")"
+	case NodeUnary:
+		text := operand(node.Children[0])
+		if precedence(node.Children[0], names) < operators[node.Value].precedence {
+			text = "(" + text + ")"
+		}
+		if node.Value == Negation {
+			return "-" + text
+		}
+		return node.Value + " " + text
+	case NodeBinary:
+		left, right := operand(node.Children[0]), operand(node.Children[1])
+		if needsParens(node, 0, names) {
+			left = "(" + left + ")"
+		}
+		if needsParens(node, 1, names) {
+			right = "(" + right + ")"
+		}
+		return left + " " + node.Value + " " + right
+	}
+	return node.Value
+}
+
+// Записывает узел в LaTeX. Операнды, у которых есть имя, записываются именем
+func renderLatex(node *Node, names map[*Node]string) string {
+	operand := func(child *Node) string {
+		if name, ok := names[child]; ok {
+			return latexName(name)
+		}
+		return renderLatex(child, names)
+	}
+	parens := func(text string) string {
+		return "\\left(" + text + "\\right)"
+	}
+	switch node.Kind {
+	case NodeNumber:
+		return latexNumber(node.Value)
+	case NodeVariable:
+		return latexName(node.Value)
+	case NodeFunction:
+		if isMatrix(node) {
+			// Строки матрицы записываются одна под другой, а элементы строки разделяются &
+			rows := make([]string, len(node.Children))
+			for i, row := range node.Children {
+				elements := make([]string, len(row.Children))
+				for j, element := range row.Children {
+					elements[j] = operand(element)
+				}
+				rows[i] = strings.Join(elements, " & ")
+			}
+			return "\\begin{bmatrix} " + strings.Join(rows, " \\\\ ") + " \\end{bmatrix}"
+		}
+		args := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			args[i] = operand(child)
+		}
+		switch node.Value {
+		case Vector:
+			return "\\begin{bmatrix} " + strings.Join(args, " & ") + " \\end{bmatrix}"
+		case "transpose":
+			if precedence(node.Children[0], names) < atomPrecedence {
+				args[0] = parens(args[0])
+			}
+			return "{" + args[0] + "}^{T}"
+		case "abs":
+			return "\\left|" + args[0] + "\\right|"
+		case "sqrt":
+			return "\\sqrt{" + args[0] + "}"
+		case "min", "max":
+			return "\\" + node.Value + parens(strings.Join(args, ", "))
+		case If:
+			return "\\begin{cases} " + args[1] + " & \\text{if } " + args[0] +
+				" \\\\ " + args[2] + " & \\text{otherwise} \\end{cases}"
+		}
+		return "\\operatorname{" + node.Value + "}" + parens(strings.Join(args, ", "))
+	case NodeUnary:
+		text := operand(node.Children[0])
+		if precedence(node.Children[0], names) < operators[node.Value].precedence {
+			text = parens(text)
+		}
+		if node.Value == Negation {
+			return "-" + text
+		}
+		return "\\lnot " + text
+	}
+
+	left, right := operand(node.Children[0]), operand(node.Children[1])
+	switch node.Value {
+	case "/":
+		// У дроби операнды отделены чертой, скобки не нужны
+		return "\\frac{" + left + "}{" + right + "}"
+	case "//":
+		return "\\left\\lfloor \\frac{" + left + "}{" + right + "} \\right\\rfloor"
+	case "^":
+		if needsParens(node, 0, names) {
+			left = parens(left)
+		}
+		return "{" + left + "}^{" + right + "}"
+	}
+	if needsParens(node, 0, names) {
+		left = parens(left)
+	}
+	if needsParens(node, 1, names) {
+		right = parens(right)
+	}
+	return left + " " + latexOperators[node.Value] + " " + right
+}
+
+// Знаки бинарных операторов в LaTeX, кроме тех, что записываются отдельно
+var latexOperators = map[string]string{
+	"+":  "+",
+	"-":  "-",
+	"*":  "\\cdot",
+	"%":  "\\bmod",
+	"<":  "<",
+	"<=": "\\le",
+	"==": "=",
+	"!=": "\\ne",
+	">=": "\\ge",
+	">":  ">",
+	And:  "\\land",
+	Or:   "\\lor",
+}
+
+// Записывает имя в LaTeX: однобуквенные имена курсивом, длинные - курсивом как одно слово
+func latexName(name string) string {
+	escaped := strings.ReplaceAll(name, "_", "\\_")
+	if len(name) == 1 {
+		return escaped
+	}
+	return "\\mathit{" + escaped + "}"
+}
+
+// Записывает число в LaTeX, переводя экспоненциальную запись в степень десяти. Мнимая единица
+// записывается после степени: "2e3i" - "2 \times 10^{3} i". Единица измерения отделяется узким пробелом
+func latexNumber(number string) string {
+	if magnitude, unit, err := SplitQuantity(number); err == nil && !unit.Empty() {
+		return latexNumber(magnitude) + " \\, " + latexUnit(unit)
+	}
+	if number != ImaginaryUnit && strings.HasSuffix(number, ImaginaryUnit) {
+		mantissa := latexNumber(strings.TrimSuffix(number, ImaginaryUnit))
+		if strings.Contains(mantissa, "^") {
+			return mantissa + " " + ImaginaryUnit
+		}
+		return mantissa + ImaginaryUnit
+	}
+	i := strings.IndexAny(number, "eE")
+	if i == -1 {
+		return number
+	}
+	exponent := strings.TrimPrefix(number[i+1:], "+")
+	return number[:i] + " \\times 10^{" + exponent + "}"
+}
+
+// Записывает единицу измерения прямым шрифтом: "kg*m/s^2" - "\mathrm{kg \cdot m / s^{2}}"
+func latexUnit(unit Unit) string {
+	var numerator, denominator []string
+	for _, p := range unit.powers {
+		power := p.power
+		if power < 0 && len(unit.powers) > 1 {
+			power = -power
+		}
+		text := p.name
+		if power != 1 {
+			text += "^{" + strconv.Itoa(power) + "}"
+		}
+		if p.power < 0 && len(unit.powers) > 1 {
+			denominator = append(denominator, text)
+		} else {
+			numerator = append(numerator, text)
+		}
+	}
+	if len(numerator) == 0 {
+		numerator = append(numerator, "1")
+	}
+	text := strings.Join(numerator, " \\cdot ")
+	for _, part := range denominator {
+		text += " / " + part
+	}
+	return "\\mathrm{" + text + "}"
+}
+
+// Проверяет, нужны ли скобки вокруг операнда бинарного оператора с индексом side (0 - левый, 1 - правый)
+func needsParens(node *Node, side int, names map[*Node]string) bool {
+	op := operators[node.Value]
+	childPrecedence := precedence(node.Children[side], names)
+	if childPrecedence != op.precedence {
+		return childPrecedence < op.precedence
+	}
+	// При равном приоритете скобки нужны со стороны, противоположной ассоциативности
+	return (side == 0) == op.rightAssoc
+}
+
+// Возвращает приоритет узла при записи: у операторов - приоритет оператора, у чисел с единицей измерения -
+// приоритет умножения, у отрицательных чисел - приоритет унарного минуса, у остальных узлов и узлов с именем -
+// наибольший, им скобки не нужны
+func precedence(node *Node, names map[*Node]string) int {
+	if _, ok := names[node]; ok {
+		return atomPrecedence
+	}
+	switch {
+	case node.Kind == NodeUnary || node.Kind == NodeBinary:
+		return operators[node.Value].precedence
+	case node.Kind == NodeNumber && strings.Contains(node.Value, " "):
+		// Число с единицей измерения записывается как произведение: "(5 m) ^ 2"
+		return operators["*"].precedence
+	case node.Kind == NodeNumber && strings.HasPrefix(node.Value, "-"):
+		return operators[Negation].precedence
+	}
+	return atomPrecedence
+}
+
+// Приоритет чисел, переменных и вызовов функций
+const atomPrecedence = 100
@@ -0,0 +1,73 @@
+package rpn
+
+import "testing"
+
+func TestInfix(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"(2 + 3) * 4", "(2 + 3) * 4"},
+		{"2 + (3 * 4)", "2 + 3 * 4"},
+		{"10 - (4 - 3)", "10 - (4 - 3)"},
+		{"(10 - 4) - 3", "10 - 4 - 3"},
+		{"(2 ^ 3) ^ 2", "(2 ^ 3) ^ 2"},
+		{"2 ^ (3 ^ 2)", "2 ^ 3 ^ 2"},
+		{"-(x + 1)", "-(x + 1)"},
+		{"not (a and b)", "not (a and b)"},
+		{"max(x, 2) * [1, y]", "max(x, 2) * [1, y]"},
+		{"a = x * y; a + a", "_1 = x * y; _1 + _1"},
+		// Имя общей операции не совпадает с переменной _1
+		{"a = x * 2; a + a + _1", "_2 = x * 2; _2 + _2 + _1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			got := Infix(r.Tree)
+			if got != tt.want {
+				t.Errorf("Infix() = %q, want %q", got, tt.want)
+			}
+			// Запись разбирается обратно в то же выражение
+			again, err := NewRPN(got)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", got, err)
+			}
+			if Infix(again.Tree) != got {
+				t.Errorf("Infix(NewRPN(%q)) = %q", got, Infix(again.Tree))
+			}
+		})
+	}
+}
+
+func TestLatex(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"1 / (x + 1)", "\\frac{1}{x + 1}"},
+		{"7 // 2", "\\left\\lfloor \\frac{7}{2} \\right\\rfloor"},
+		{"(a + b) ^ 2", "{\\left(a + b\\right)}^{2}"},
+		{"2 * speed", "2 \\cdot \\mathit{speed}"},
+		{"abs(x) + sqrt(y)", "\\left|x\\right| + \\sqrt{y}"},
+		{"if(x <= 0, 1, 2)", "\\begin{cases} 1 & \\text{if } x \\le 0 \\\\ 2 & \\text{otherwise} \\end{cases}"},
+		{"2.5e3", "2.5 \\times 10^{3}"},
+		{"2e3i", "2 \\times 10^{3} i"},
+		{"[[1, 2], [3, 4]]", "\\begin{bmatrix} 1 & 2 \\\\ 3 & 4 \\end{bmatrix}"},
+		{"9.8 m/s^2", "9.8 \\, \\mathrm{m / s^{2}}"},
+		{"a = x + 1; a * a", "\\mathit{\\_1} = x + 1,\\quad \\mathit{\\_1} \\cdot \\mathit{\\_1}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			if got := Latex(r.Tree); got != tt.want {
+				t.Errorf("Latex() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
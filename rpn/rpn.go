@@ -1,109 +1,384 @@
 package rpn
 
 import (
-	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 )
 
-// Структура, хранящая в себе выражение в обычной и обратной польской нотациях
+// Токен унарного минуса в обратной польской нотации
+const Negation = "neg"
+
+// Логические операции
+const (
+	And = "and"
+	Or  = "or"
+	Not = "not"
+)
+
+// Условная функция if(условие, a, b): вычисляется только та ветка, которая нужна
+const If = "if"
+
+// Структура, хранящая в себе выражение в обычной и обратной польской нотациях и его дерево
 type RPN struct {
 	SNExpression  string
 	RPNExpression string
+	Tree          *Node
+	options       Options
+}
+
+// Настройки разбора выражения
+type Options struct {
+	// Пользовательские функции, которые можно вызывать в выражении
+	Functions Definitions
+	// Запись выражения: обычная (по умолчанию), постфиксная или префиксная
+	Notation string
 }
 
 // Создает новый экземпляр структуры RPN
 func NewRPN(expression string) (*RPN, error) {
-	rpn := &RPN{}
-	rpn.SNExpression = strings.TrimSpace(expression)
-	err := rpn.convertToRPN()
+	return Parse(expression, Options{})
+}
+
+// Создает новый экземпляр структуры RPN с заданными настройками разбора
+func Parse(expression string, options Options) (*RPN, error) {
+	rpn := &RPN{SNExpression: expression, options: options}
+	var err error
+	switch options.Notation {
+	case "", NotationInfix:
+		err = rpn.convertToRPN()
+	case NotationRPN, NotationPrefix:
+		err = rpn.convertFromStackNotation()
+	default:
+		return nil, &ParseError{Code: ErrUnknownNotation, Message: "Неизвестная запись выражения", Token: options.Notation}
+	}
 	if err != nil {
 		return nil, err
 	}
+	resolveConversions(rpn.Tree)
 	return rpn, nil
 }
 
-// Проверяет выражение на валидность (все равно нужно все символы выражения писать через пробел, кроме отрицательных чисел)
-func (r *RPN) validateExpression() error {
-	nonValid := []string{
-		"- - ", "- +", "- *", "- /",
-		"+ - ", "+ +", "+ *", "+ /",
-		"* - ", "* +", "* *", "* /",
-		"/ - ", "/ +", "/ *", "/ /",
+// Возвращает описание встроенной или пользовательской функции по имени
+func (r *RPN) function(name string) (Function, bool) {
+	if function, ok := Functions[name]; ok {
+		return function, true
 	}
-	for _, el := range nonValid {
-		if strings.Contains(r.SNExpression, el) {
-			return errors.New("Неправильное расположение знаков")
-		}
+	if definition, ok := r.options.Functions[name]; ok {
+		return Function{MinArgs: len(definition.Params), MaxArgs: len(definition.Params)}, true
 	}
-	if strings.ContainsAny(r.SNExpression, "()") {
-		return errors.New("Работа со скобками пока не поддерживается")
+	return Function{}, false
+}
+
+// Приоритет и ассоциативность оператора
+type operator struct {
+	precedence int
+	rightAssoc bool
+}
+
+// Операторы, которые могут встретиться в выражении
+var operators = map[string]operator{
+	Or:       {precedence: 1},
+	And:      {precedence: 2},
+	Not:      {precedence: 3, rightAssoc: true},
+	"<":      {precedence: 4},
+	"<=":     {precedence: 4},
+	"==":     {precedence: 4},
+	"!=":     {precedence: 4},
+	">=":     {precedence: 4},
+	">":      {precedence: 4},
+	"+":      {precedence: 5},
+	"-":      {precedence: 5},
+	"*":      {precedence: 6},
+	"/":      {precedence: 6},
+	"%":      {precedence: 6},
+	"//":     {precedence: 6},
+	Negation: {precedence: 7, rightAssoc: true},
+	"^":      {precedence: 8, rightAssoc: true},
+}
+
+// Проверяет, является ли оператор унарным и записывается ли перед операндом
+func isPrefix(op string) bool {
+	return op == Negation || op == Not
+}
+
+// Переводит выражение из обычной в обратную польскую нотацию. Выражение может быть программой
+// из нескольких инструкций через ";": все, кроме последней, присваивают значение имени ("a = 2 * 3"),
+// а последняя вычисляет результат. Имя в следующих инструкциях ссылается на узел дерева своего значения,
+// поэтому вся программа становится одним графом, в котором каждое значение вычисляется один раз
+func (r *RPN) convertToRPN() error {
+	tokens, err := Tokenize(r.SNExpression)
+	if err != nil {
+		return err
 	}
-	nonOutside := []string{
-		"-", "+", "*", "/",
+	if len(tokens) == 0 {
+		return &ParseError{Code: ErrEmptyExpression, Message: "Пустое выражение", Pos: 0}
 	}
-	for _, el := range nonOutside {
-		if string(r.SNExpression[0]) == string(el) {
-			if el == "-" {
-				if string(r.SNExpression[1]) == " " {
-					return errors.New("Неправильное расположение знаков")
-				}
-			} else {
-				return errors.New("Неправильное расположение знаков")
+	tokens, err = expandBrackets(tokens)
+	if err != nil {
+		return err
+	}
+
+	bindings := make(map[string]*Node)
+	var statements []string
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].Type != TokenSemicolon {
+			continue
+		}
+		statement := tokens[start:i]
+		last := i == len(tokens)
+		start = i + 1
+		if len(statement) == 0 {
+			separator := tokens[len(tokens)-1]
+			if !last {
+				separator = tokens[i]
+			}
+			return newParseError(ErrEmptyStatement, "Пустая инструкция", separator)
+		}
+
+		isBinding := len(statement) > 1 && statement[0].Type == TokenIdentifier && statement[1].Type == TokenAssign
+		if last && isBinding {
+			return newParseError(ErrInvalidStatement, "Программа должна заканчиваться выражением", statement[1])
+		}
+		if !last && !isBinding {
+			return newParseError(
+				ErrInvalidStatement,
+				"Перед \";\" должно стоять присваивание вида имя = выражение",
+				statement[0],
+			)
+		}
+		if !isBinding {
+			tree, output, err := r.parseStatement(statement, bindings)
+			if err != nil {
+				return err
 			}
+			r.Tree = tree
+			statements = append(statements, output)
+			break
 		}
-		if string(r.SNExpression[len(r.SNExpression)-1]) == string(el) {
-			return errors.New("Неправильное расположение знаков")
+
+		name := statement[0]
+		if _, ok := r.function(name.Text); ok {
+			return newParseError(ErrInvalidStatement, "Имя функции нельзя использовать как имя значения", name)
 		}
+		if len(statement) == 2 {
+			return newParseError(ErrEmptyStatement, "Пропущено значение после знака =", statement[1])
+		}
+		tree, output, err := r.parseStatement(statement[2:], bindings)
+		if err != nil {
+			return err
+		}
+		bindings[name.Text] = tree
+		statements = append(statements, name.Text+" = "+output)
 	}
+
+	r.RPNExpression = strings.Join(statements, "; ")
 	return nil
 }
 
-// Переводит выражение из обычной в обратную польскую нотацию
-func (r *RPN) convertToRPN() error {
-	err := r.validateExpression()
-	if err != nil {
-		return err
+// Разбирает одну инструкцию алгоритмом сортировочной станции. Возвращает дерево инструкции
+// и ее запись в обратной польской нотации. Имена из bindings заменяются узлами их значений
+func (r *RPN) parseStatement(tokens []Token, bindings map[string]*Node) (*Node, string, error) {
+	var output []string
+	var stack []Token
+	// Стек поддеревьев, из которых собирается дерево выражения
+	var nodes []*Node
+	// Количество аргументов у вызовов функций, которые сейчас разбираются
+	var argCounts []int
+
+	// Последнее добавленное число. Унарный минус прямо перед числом становится частью числа
+	var lastNumber *Node
+
+	// Добавляет токен в выражение в обратной польской нотации и строит для него узел дерева.
+	// Переменная с именем "neg" - не унарный минус, поэтому проверяется и тип токена
+	emit := func(token Token, arity int) {
+		isNegation := token.Type == TokenOperator && token.Text == Negation
+		if isNegation && nodes[len(nodes)-1] == lastNumber {
+			lastNumber.Value = Negate(lastNumber.Value)
+			lastNumber.Pos = token.Pos
+			output[len(output)-1] = lastNumber.Value
+			return
+		}
+		node := &Node{Kind: NodeNumber, Value: token.Text, Pos: token.Pos}
+		lastNumber = nil
+		text := token.Text
+		switch {
+		case token.Type == TokenNumber:
+			lastNumber = node
+		case token.Type == TokenVariable:
+			if bound, ok := bindings[token.Text]; ok {
+				output = append(output, text)
+				nodes = append(nodes, bound)
+				return
+			}
+			node.Kind = NodeVariable
+		case token.Type == TokenIdentifier:
+			if definition, ok := r.options.Functions[token.Text]; ok {
+				// Вызов пользовательской функции заменяется ее телом с подставленными аргументами
+				output = append(output, text)
+				call := definition.instantiate(nodes[len(nodes)-arity:], token.Pos)
+				nodes = append(nodes[:len(nodes)-arity], call)
+				return
+			}
+			node.Kind = NodeFunction
+			if Functions[token.Text].IsVariadic() {
+				text = fmt.Sprintf("%s:%d", token.Text, arity)
+			}
+		case token.Type == TokenOperator && isPrefix(token.Text):
+			node.Kind = NodeUnary
+			arity = 1
+		case token.Type == TokenOperator:
+			node.Kind = NodeBinary
+			arity = 2
+		}
+		output = append(output, text)
+		node.Children = append(node.Children, nodes[len(nodes)-arity:]...)
+		nodes = append(nodes[:len(nodes)-arity], node)
 	}
 
-	operators := map[string]int{"+": 1, "-": 1, "*": 2, "/": 2}
-	var output []string
-	var stack []string
+	// Выталкивает из стека в выход все операторы до ближайшей открывающей скобки
+	popOperators := func() {
+		for len(stack) > 0 && stack[len(stack)-1].Type == TokenOperator {
+			emit(stack[len(stack)-1], 0)
+			stack = stack[:len(stack)-1]
+		}
+	}
 
-	tokens := strings.Fields(r.SNExpression)
+	// Проверяет, открывает ли скобка на вершине стека список аргументов функции
+	isCallParen := func() bool {
+		return len(stack) > 1 && stack[len(stack)-2].Type == TokenIdentifier
+	}
+
+	// Ожидается ли сейчас операнд (число, функция, открывающая скобка или унарный минус)
+	expectOperand := true
 
 	for i, token := range tokens {
-		if IsNumeric(token) || (token == "-" && (i == 0 || !IsNumeric(tokens[i-1]))) {
-			output = append(output, token)
-		} else if token == "(" {
-			stack = append(stack, token)
-		} else if token == ")" {
-			for len(stack) > 0 && stack[len(stack)-1] != "(" {
-				output = append(output, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
+		if expectOperand {
+			switch {
+			case token.Type == TokenNumber:
+				emit(token, 0)
+				expectOperand = false
+			case token.Type == TokenIdentifier:
+				isCall := i+1 < len(tokens) && tokens[i+1].Type == TokenLeftParen
+				_, isFunction := r.function(token.Text)
+				switch {
+				case isCall && !isFunction:
+					return nil, "", newParseError(ErrUnknownIdentifier, "Неизвестная функция", token)
+				case isCall:
+					stack = append(stack, token)
+				case isFunction:
+					return nil, "", newParseError(ErrInvalidCall, "После имени функции должна идти скобка", token)
+				default:
+					emit(Token{Type: TokenVariable, Text: token.Text, Pos: token.Pos}, 0)
+					expectOperand = false
+				}
+			case token.Type == TokenLeftParen:
+				stack = append(stack, token)
+				if isCallParen() {
+					argCounts = append(argCounts, 1)
+				}
+			case token.Text == "-":
+				stack = append(stack, Token{Type: TokenOperator, Text: Negation, Pos: token.Pos})
+			case token.Text == Not:
+				stack = append(stack, token)
+			case token.Type == TokenRightParen:
+				return nil, "", newParseError(ErrEmptyParentheses, "Пустые скобки или знак перед закрывающей скобкой", token)
+			case token.Type == TokenComma:
+				return nil, "", newParseError(ErrMisplacedComma, "Пропущен аргумент функции", token)
+			default:
+				return nil, "", newParseError(ErrMisplacedOperator, "Неправильное расположение знаков", token)
 			}
+			continue
+		}
+
+		switch token.Type {
+		case TokenRightParen:
+			popOperators()
+			if len(stack) == 0 {
+				return nil, "", newParseError(ErrUnmatchedParen, "Лишняя закрывающая скобка", token)
+			}
+			isCall := isCallParen()
 			stack = stack[:len(stack)-1]
-		} else {
-			for len(stack) > 0 && operators[stack[len(stack)-1]] >= operators[token] {
-				output = append(output, stack[len(stack)-1])
+			if isCall {
+				function := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				args := argCounts[len(argCounts)-1]
+				argCounts = argCounts[:len(argCounts)-1]
+				if spec, _ := r.function(function.Text); !spec.Accepts(args) {
+					return nil, "", newParseError(
+						ErrInvalidArity,
+						fmt.Sprintf("Неверное количество аргументов функции: %d", args),
+						function,
+					)
+				}
+				emit(function, args)
+			}
+		case TokenComma:
+			popOperators()
+			if len(stack) == 0 || !isCallParen() {
+				return nil, "", newParseError(ErrMisplacedComma, "Запятая вне вызова функции", token)
+			}
+			argCounts[len(argCounts)-1]++
+			expectOperand = true
+		case TokenLeftParen:
+			return nil, "", newParseError(ErrMissingOperator, "Пропущен знак перед открывающей скобкой", token)
+		case TokenAssign:
+			return nil, "", newParseError(ErrMisplacedOperator, "Присваивание недопустимо в выражении", token)
+		case TokenOperator:
+			if isPrefix(token.Text) {
+				return nil, "", newParseError(ErrMissingOperator, "Пропущен знак перед отрицанием", token)
+			}
+			current := operators[token.Text]
+			for len(stack) > 0 && stack[len(stack)-1].Type == TokenOperator {
+				top := operators[stack[len(stack)-1].Text]
+				if top.precedence < current.precedence ||
+					(top.precedence == current.precedence && current.rightAssoc) {
+					break
+				}
+				emit(stack[len(stack)-1], 0)
 				stack = stack[:len(stack)-1]
 			}
 			stack = append(stack, token)
+			expectOperand = true
+		default:
+			return nil, "", newParseError(ErrMissingOperator, "Пропущен знак между операндами", token)
+		}
+	}
+
+	if expectOperand {
+		last := tokens[len(tokens)-1]
+		return nil, "", &ParseError{
+			Code:    ErrTrailingOperator,
+			Message: "Выражение не может заканчиваться знаком",
+			Token:   last.Text,
+			Pos:     last.Pos,
 		}
 	}
 
 	for len(stack) > 0 {
-		output = append(output, stack[len(stack)-1])
+		top := stack[len(stack)-1]
+		if top.Type == TokenLeftParen {
+			return nil, "", newParseError(ErrUnclosedParen, "Не закрыта открывающая скобка", top)
+		}
+		emit(top, 0)
 		stack = stack[:len(stack)-1]
 	}
 
-	r.RPNExpression = strings.Join(output, " ")
-	return nil
+	return nodes[0], strings.Join(output, " "), nil
+}
+
+// Меняет знак у числа, записанного строкой
+func Negate(number string) string {
+	if strings.HasPrefix(number, "-") {
+		return number[1:]
+	}
+	return "-" + number
 }
 
 // Проверяет, является ли символ оператором
 func IsOperator(char rune) bool {
-	return char == '+' || char == '-' || char == '*' || char == '/'
+	return char == '+' || char == '-' || char == '*' || char == '/' || char == '^' || char == '%'
 }
 
 // Проверяет, является ли символ числом
@@ -0,0 +1,191 @@
+package rpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInfix(t *testing.T) {
+	tests := []struct {
+		expression string
+		rpn        string
+	}{
+		{"2 + 3 * 4", "2 3 4 * +"},
+		{"(2 + 3) * 4", "2 3 + 4 *"},
+		{"2 - 3 - 4", "2 3 - 4 -"},
+		{"-2 * 3", "-2 3 *"},
+		{"2 ^ 3 ^ 2", "2 3 2 ^ ^"},
+		{"-2 ^ 2", "2 2 ^ neg"},
+		{"-(2 + 3)", "2 3 + neg"},
+		{"2 * -(3 - 1)", "2 3 1 - neg *"},
+		{"--2", "2"},
+		{"2*(3+4)", "2 3 4 + *"},
+		{"((1))", "1"},
+		{"7 // 2 % 3", "7 2 // 3 %"},
+		{"min(1, 2, 3) + abs(-4)", "1 2 3 min:3 -4 abs +"},
+		{"2 * x1 - -y", "2 x1 * y neg -"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			if r.RPNExpression != tt.rpn {
+				t.Errorf("RPNExpression = %q, want %q", r.RPNExpression, tt.rpn)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		code       string
+		token      string
+		pos        int
+	}{
+		{"", ErrEmptyExpression, "", 0},
+		{"   ", ErrEmptyExpression, "", 0},
+		{"2 +", ErrTrailingOperator, "+", 2},
+		{"* 2", ErrMisplacedOperator, "*", 0},
+		{"2 3", ErrMissingOperator, "3", 2},
+		{"()", ErrEmptyParentheses, ")", 1},
+		{"(1 + 2", ErrUnclosedParen, "(", 0},
+		{"1 + 2)", ErrUnmatchedParen, ")", 5},
+		{"foo(1)", ErrUnknownIdentifier, "foo", 0},
+		{"abs(1, 2)", ErrInvalidArity, "abs", 0},
+		{"1, 2", ErrMisplacedComma, ",", 1},
+		{"if(1, 2)", ErrInvalidArity, "if", 0},
+		{"x = 1", ErrInvalidStatement, "=", 2},
+		{"2; 3", ErrInvalidStatement, "2", 0},
+		{"x = ; x", ErrEmptyStatement, "=", 2},
+		{"x = 1;; x", ErrEmptyStatement, ";", 6},
+		{"abs = 1; abs", ErrInvalidStatement, "abs", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := NewRPN(tt.expression)
+			parseErr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("NewRPN(%q) error = %v, want *ParseError", tt.expression, err)
+			}
+			if parseErr.Code != tt.code || parseErr.Token != tt.token || parseErr.Pos != tt.pos {
+				t.Errorf("NewRPN(%q) error = %s %q at %d, want %s %q at %d",
+					tt.expression, parseErr.Code, parseErr.Token, parseErr.Pos, tt.code, tt.token, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	r, err := NewRPN("-(1 + 2) * 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []NodeKind
+	var values []string
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		kinds = append(kinds, node.Kind)
+		values = append(values, node.Value)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(r.Tree)
+	wantKinds := []NodeKind{NodeBinary, NodeUnary, NodeBinary, NodeNumber, NodeNumber, NodeNumber}
+	wantValues := []string{"*", Negation, "+", "1", "2", "3"}
+	if !reflect.DeepEqual(kinds, wantKinds) || !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("tree = %v %q, want %v %q", kinds, values, wantKinds, wantValues)
+	}
+}
+
+func TestParseVariableNamedNeg(t *testing.T) {
+	tests := []struct {
+		expression string
+		rpn        string
+	}{
+		{"neg", "neg"},
+		{"neg + 1", "neg 1 +"},
+		{"2 * neg", "2 neg *"},
+		{"-neg", "neg neg"},
+		{"-2 * neg", "-2 neg *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			if r.RPNExpression != tt.rpn {
+				t.Errorf("RPNExpression = %q, want %q", r.RPNExpression, tt.rpn)
+			}
+			variables := r.Tree.Variables()
+			if len(variables) != 1 || variables[0].Value != Negation {
+				t.Errorf("Variables() = %v, want one variable %q", variables, Negation)
+			}
+		})
+	}
+}
+
+func TestParseLogic(t *testing.T) {
+	tests := []struct {
+		expression string
+		rpn        string
+	}{
+		{"a < b and not c", "a b < c not and"},
+		{"a or b and c", "a b c and or"},
+		{"1 + 2 == 3", "1 2 + 3 =="},
+		{"not not a", "a not not"},
+		{"if(x > 0, x, -x)", "x 0 > x x neg if"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			if r.RPNExpression != tt.rpn {
+				t.Errorf("RPNExpression = %q, want %q", r.RPNExpression, tt.rpn)
+			}
+		})
+	}
+}
+
+func TestParseBindings(t *testing.T) {
+	tests := []struct {
+		expression string
+		rpn        string
+		infix      string
+		// Количество разных узлов в графе: значение имени вычисляется один раз
+		nodes int
+	}{
+		{"a = 2 * x; a + a", "a = 2 x *; a a +", "_1 = 2 * x; _1 + _1", 4},
+		{"a = 1; b = a + 1; b * a", "a = 1; b = a 1 +; b a *", "(1 + 1) * 1", 4},
+		{"x = 3; x", "x = 3; x", "3", 1},
+		{"a = y; a = a + 1; a", "a = y; a = a 1 +; a", "y + 1", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r, err := NewRPN(tt.expression)
+			if err != nil {
+				t.Fatalf("NewRPN(%q): %v", tt.expression, err)
+			}
+			if r.RPNExpression != tt.rpn {
+				t.Errorf("RPNExpression = %q, want %q", r.RPNExpression, tt.rpn)
+			}
+			if got := Infix(r.Tree); got != tt.infix {
+				t.Errorf("Infix() = %q, want %q", got, tt.infix)
+			}
+			nodes := 0
+			r.Tree.Walk(func(*Node) error {
+				nodes++
+				return nil
+			})
+			if nodes != tt.nodes {
+				t.Errorf("nodes = %d, want %d", nodes, tt.nodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package rpn
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Функции перевода величин: to(x, km/h) переводит x в заданную единицу, si(x) - в основные единицы СИ
+const (
+	To = "to"
+	SI = "si"
+)
+
+// Основные единицы СИ, через которые выражается размерность любой единицы
+var baseUnits = [...]string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+// Размерность единицы: степени основных единиц СИ в порядке baseUnits
+type Dimension [len(baseUnits)]int
+
+// Единица из таблицы: во сколько раз она больше основной единицы той же размерности и сама размерность
+type unitDefinition struct {
+	factor    *big.Rat
+	dimension Dimension
+}
+
+// Создает описание единицы. Множитель задается дробью, например "1/1000"
+func newUnitDefinition(factor string, dimension Dimension) unitDefinition {
+	f, _ := new(big.Rat).SetString(factor)
+	return unitDefinition{factor: f, dimension: dimension}
+}
+
+// Единицы измерения, которые можно писать после чисел
+var units = map[string]unitDefinition{
+	"m":   newUnitDefinition("1", Dimension{1, 0, 0, 0, 0, 0, 0}),
+	"km":  newUnitDefinition("1000", Dimension{1, 0, 0, 0, 0, 0, 0}),
+	"cm":  newUnitDefinition("1/100", Dimension{1, 0, 0, 0, 0, 0, 0}),
+	"mm":  newUnitDefinition("1/1000", Dimension{1, 0, 0, 0, 0, 0, 0}),
+	"kg":  newUnitDefinition("1", Dimension{0, 1, 0, 0, 0, 0, 0}),
+	"g":   newUnitDefinition("1/1000", Dimension{0, 1, 0, 0, 0, 0, 0}),
+	"mg":  newUnitDefinition("1/1000000", Dimension{0, 1, 0, 0, 0, 0, 0}),
+	"s":   newUnitDefinition("1", Dimension{0, 0, 1, 0, 0, 0, 0}),
+	"ms":  newUnitDefinition("1/1000", Dimension{0, 0, 1, 0, 0, 0, 0}),
+	"h":   newUnitDefinition("3600", Dimension{0, 0, 1, 0, 0, 0, 0}),
+	"A":   newUnitDefinition("1", Dimension{0, 0, 0, 1, 0, 0, 0}),
+	"K":   newUnitDefinition("1", Dimension{0, 0, 0, 0, 1, 0, 0}),
+	"mol": newUnitDefinition("1", Dimension{0, 0, 0, 0, 0, 1, 0}),
+	"cd":  newUnitDefinition("1", Dimension{0, 0, 0, 0, 0, 0, 1}),
+	"L":   newUnitDefinition("1/1000", Dimension{3, 0, 0, 0, 0, 0, 0}),
+	"Hz":  newUnitDefinition("1", Dimension{0, 0, -1, 0, 0, 0, 0}),
+	"N":   newUnitDefinition("1", Dimension{1, 1, -2, 0, 0, 0, 0}),
+	"Pa":  newUnitDefinition("1", Dimension{-1, 1, -2, 0, 0, 0, 0}),
+	"J":   newUnitDefinition("1", Dimension{2, 1, -2, 0, 0, 0, 0}),
+	"W":   newUnitDefinition("1", Dimension{2, 1, -3, 0, 0, 0, 0}),
+	"C":   newUnitDefinition("1", Dimension{0, 0, 1, 1, 0, 0, 0}),
+	"V":   newUnitDefinition("1", Dimension{2, 1, -3, -1, 0, 0, 0}),
+}
+
+// Проверяет, есть ли единица с таким именем
+func IsUnit(name string) bool {
+	_, ok := units[name]
+	return ok
+}
+
+// Единица из таблицы в целой степени
+type unitPower struct {
+	name  string
+	power int
+}
+
+// Единица измерения величины - произведение единиц из таблицы в целых степенях, например "kg*m/s^2".
+// Единицы хранятся в порядке первого появления, одинаковые объединяются. Пустая единица - у безразмерных чисел
+type Unit struct {
+	powers []unitPower
+}
+
+// Разбирает запись единицы: имена из таблицы через "*" и "/", после имени может стоять целая степень ("s^2",
+// "s^-1"). Пробелов внутри записи нет. Пустая строка - безразмерная величина
+func ParseUnit(s string) (Unit, error) {
+	var unit Unit
+	if s == "" {
+		return unit, nil
+	}
+	sign := 1
+	for i := 0; i < len(s); {
+		end := i
+		for end < len(s) && (isLetter(s[end]) || isDigit(s[end])) {
+			end++
+		}
+		name := s[i:end]
+		if !IsUnit(name) {
+			return Unit{}, fmt.Errorf("Неизвестная единица измерения: %s", s)
+		}
+		power := 1
+		if end < len(s) && s[end] == '^' {
+			exponent := end + 1
+			if exponent < len(s) && s[exponent] == '-' {
+				exponent++
+			}
+			for exponent < len(s) && isDigit(s[exponent]) {
+				exponent++
+			}
+			p, err := strconv.Atoi(s[end+1 : exponent])
+			if err != nil {
+				return Unit{}, fmt.Errorf("Некорректная степень единицы измерения: %s", s)
+			}
+			power, end = p, exponent
+		}
+		unit = unit.Mul(Unit{powers: []unitPower{{name, sign * power}}})
+		if end == len(s) {
+			break
+		}
+		if s[end] != '*' && s[end] != '/' {
+			return Unit{}, fmt.Errorf("Некорректная единица измерения: %s", s)
+		}
+		sign = 1
+		if s[end] == '/' {
+			sign = -1
+		}
+		i = end + 1
+		if i == len(s) {
+			return Unit{}, fmt.Errorf("Некорректная единица измерения: %s", s)
+		}
+	}
+	return unit, nil
+}
+
+// Записывает единицу: единицы в положительных степенях через "*", затем единицы в отрицательных степенях
+// через "/" ("kg*m/s^2"). Если положительных степеней нет, отрицательные пишутся со знаком: "s^-1"
+func (u Unit) String() string {
+	var numerator []string
+	for _, p := range u.powers {
+		if p.power > 0 {
+			numerator = append(numerator, formatPower(p.name, p.power))
+		}
+	}
+	if len(numerator) == 0 {
+		parts := make([]string, 0, len(u.powers))
+		for _, p := range u.powers {
+			parts = append(parts, formatPower(p.name, p.power))
+		}
+		return strings.Join(parts, "*")
+	}
+	res := strings.Join(numerator, "*")
+	for _, p := range u.powers {
+		if p.power < 0 {
+			res += "/" + formatPower(p.name, -p.power)
+		}
+	}
+	return res
+}
+
+// Записывает единицу в степени: степень 1 не пишется
+func formatPower(name string, power int) string {
+	if power == 1 {
+		return name
+	}
+	return name + "^" + strconv.Itoa(power)
+}
+
+// Проверяет, что у величины нет единицы измерения
+func (u Unit) Empty() bool {
+	return len(u.powers) == 0
+}
+
+// Возвращает размерность единицы
+func (u Unit) Dimension() Dimension {
+	var dimension Dimension
+	for _, p := range u.powers {
+		for i, power := range units[p.name].dimension {
+			dimension[i] += power * p.power
+		}
+	}
+	return dimension
+}
+
+// Возвращает, во сколько раз единица больше основной единицы СИ той же размерности
+func (u Unit) Factor() *big.Rat {
+	factor := big.NewRat(1, 1)
+	for _, p := range u.powers {
+		factor.Mul(factor, ratPow(units[p.name].factor, p.power))
+	}
+	return factor
+}
+
+// Произведение единиц. Одинаковые единицы складывают степени, единицы в нулевой степени пропадают
+func (u Unit) Mul(v Unit) Unit {
+	powers := make([]unitPower, 0, len(u.powers)+len(v.powers))
+	powers = append(powers, u.powers...)
+	for _, p := range v.powers {
+		merged := false
+		for i := range powers {
+			if powers[i].name == p.name {
+				powers[i].power += p.power
+				merged = true
+			}
+		}
+		if !merged {
+			powers = append(powers, p)
+		}
+	}
+	res := Unit{}
+	for _, p := range powers {
+		if p.power != 0 {
+			res.powers = append(res.powers, p)
+		}
+	}
+	return res
+}
+
+// Возводит единицу в целую степень
+func (u Unit) Pow(n int) Unit {
+	res := Unit{}
+	if n == 0 {
+		return res
+	}
+	for _, p := range u.powers {
+		res.powers = append(res.powers, unitPower{p.name, p.power * n})
+	}
+	return res
+}
+
+// Извлекает из единицы корень степени n. Возвращает false, если степени единиц на n не делятся
+func (u Unit) Root(n int) (Unit, bool) {
+	res := Unit{}
+	for _, p := range u.powers {
+		if p.power%n != 0 {
+			return Unit{}, false
+		}
+		res.powers = append(res.powers, unitPower{p.name, p.power / n})
+	}
+	return res, true
+}
+
+// Записывает размерность единицы в основных единицах СИ: у "km/h" это "m/s", у "J" - "m^2*kg/s^2"
+func (u Unit) SI() Unit {
+	res := Unit{}
+	for i, power := range u.Dimension() {
+		if power != 0 {
+			res.powers = append(res.powers, unitPower{baseUnits[i], power})
+		}
+	}
+	return res
+}
+
+// Возвращает множитель, на который нужно умножить число в единице from, чтобы получить число в единице to.
+// Размерности единиц должны совпадать
+func Conversion(from, to Unit) (*big.Rat, error) {
+	if from.Dimension() != to.Dimension() {
+		return nil, fmt.Errorf("Нельзя перевести %s в %s: размерности не совпадают", describeUnit(from), describeUnit(to))
+	}
+	return new(big.Rat).Quo(from.Factor(), to.Factor()), nil
+}
+
+// Приводит величины к общей единице, например для сложения или сравнения. Общая единица - самая мелкая,
+// поэтому числа только умножаются на целые множители, если это возможно. Возвращает общую единицу
+// и множители для каждой величины. Размерности всех единиц должны совпадать
+func Common(us []Unit) (Unit, []*big.Rat, error) {
+	common := us[0]
+	for _, u := range us[1:] {
+		if u.Dimension() != common.Dimension() {
+			return Unit{}, nil, fmt.Errorf(
+				"Несовместимые единицы измерения: %s и %s",
+				describeUnit(common),
+				describeUnit(u),
+			)
+		}
+		if u.Factor().Cmp(common.Factor()) < 0 {
+			common = u
+		}
+	}
+	factors := make([]*big.Rat, len(us))
+	for i, u := range us {
+		factors[i], _ = Conversion(u, common)
+	}
+	return common, factors, nil
+}
+
+// Приводит единицы одной размерности из разных величин к самой мелкой из них перед умножением или делением:
+// "2 km * 3 m" считается как "2000 m * 3 m". Возвращает новые единицы и множители для каждой величины
+func Align(us []Unit) ([]Unit, []*big.Rat) {
+	var names []string
+	for _, u := range us {
+		for _, p := range u.powers {
+			names = append(names, p.name)
+		}
+	}
+	targets := make(map[string]string)
+	for _, name := range names {
+		target := name
+		for _, other := range names {
+			if units[other].dimension == units[name].dimension && units[other].factor.Cmp(units[target].factor) < 0 {
+				target = other
+			}
+		}
+		targets[name] = target
+	}
+
+	aligned := make([]Unit, len(us))
+	factors := make([]*big.Rat, len(us))
+	for i, u := range us {
+		factors[i] = big.NewRat(1, 1)
+		for _, p := range u.powers {
+			target := targets[p.name]
+			ratio := new(big.Rat).Quo(units[p.name].factor, units[target].factor)
+			factors[i].Mul(factors[i], ratPow(ratio, p.power))
+			aligned[i] = aligned[i].Mul(Unit{powers: []unitPower{{target, p.power}}})
+		}
+	}
+	return aligned, factors
+}
+
+// Разделяет значение на число (или вектор) и единицу измерения: "2.5 m/s" - "2.5" и "m/s".
+// Единица записывается через пробел после числа, у значений без единицы она пустая
+func SplitQuantity(value string) (string, Unit, error) {
+	i := strings.LastIndexByte(value, ' ')
+	if i == -1 || i+1 == len(value) || !isLetter(value[i+1]) {
+		return value, Unit{}, nil
+	}
+	unit, err := ParseUnit(value[i+1:])
+	if err != nil {
+		return "", Unit{}, err
+	}
+	return strings.TrimSpace(value[:i]), unit, nil
+}
+
+// Записывает число вместе с единицей измерения
+func FormatQuantity(magnitude string, unit Unit) string {
+	if unit.Empty() {
+		return magnitude
+	}
+	return magnitude + " " + unit.String()
+}
+
+// Описывает единицу в сообщении об ошибке
+func describeUnit(u Unit) string {
+	if u.Empty() {
+		return "безразмерная величина"
+	}
+	return u.String()
+}
+
+// Возводит дробь в целую степень
+func ratPow(r *big.Rat, n int) *big.Rat {
+	res := big.NewRat(1, 1)
+	base := new(big.Rat).Set(r)
+	if n < 0 {
+		base.Inv(base)
+		n = -n
+	}
+	for ; n > 0; n-- {
+		res.Mul(res, base)
+	}
+	return res
+}
+
+// Считывает единицу измерения, записанную после числа, начиная с позиции start. Единица - имя из таблицы,
+// за которым без пробелов могут идти степень и другие единицы через "*" и "/": "5 m", "9.8 m/s^2".
+// Если spaced равно false, единица должна стоять вплотную к числу. Имя, за которым идет скобка, - вызов
+// функции, а не единица. Возвращает позицию после единицы и ее каноническую запись или start и пустую
+// строку, если единицы нет
+func scanUnit(expression string, start int, spaced bool) (int, string) {
+	i := start
+	for spaced && i < len(expression) && isSpace(expression[i]) {
+		i++
+	}
+	end := scanUnitName(expression, i)
+	if end == i {
+		return start, ""
+	}
+	for {
+		if end+1 < len(expression) && expression[end] == '^' {
+			exponent := end + 1
+			if expression[exponent] == '-' {
+				exponent++
+			}
+			digits := exponent
+			for digits < len(expression) && isDigit(expression[digits]) {
+				digits++
+			}
+			if digits == exponent {
+				break
+			}
+			end = digits
+		}
+		if end+1 >= len(expression) || (expression[end] != '*' && expression[end] != '/') {
+			break
+		}
+		next := scanUnitName(expression, end+1)
+		if next == end+1 {
+			break
+		}
+		end = next
+	}
+	unit, err := ParseUnit(expression[i:end])
+	if err != nil {
+		return start, ""
+	}
+	return end, unit.String()
+}
+
+// Считывает имя единицы с позиции start. Возвращает start, если там нет единицы или это имя функции
+func scanUnitName(expression string, start int) int {
+	end := start
+	for end < len(expression) && (isLetter(expression[end]) || isDigit(expression[end])) {
+		end++
+	}
+	if end == start || !IsUnit(expression[start:end]) {
+		return start
+	}
+	next := end
+	for next < len(expression) && isSpace(expression[next]) {
+		next++
+	}
+	if next < len(expression) && expression[next] == '(' {
+		return start
+	}
+	return end
+}
+
+// Заменяет единицу во втором аргументе to на число 1 с этой единицей: в to(x, km/h) имена km и h -
+// единицы, а не переменные. Единица может быть записана и числом с единицей: to(x, 1 km/h)
+func resolveConversions(tree *Node) {
+	tree.Walk(func(node *Node) error {
+		if node.Kind != NodeFunction || node.Value != To || len(node.Children) != 2 {
+			return nil
+		}
+		target := node.Children[1]
+		if unit, ok := unitExpression(target); ok {
+			node.Children[1] = &Node{Kind: NodeNumber, Value: FormatQuantity("1", unit), Pos: target.Pos}
+		}
+		return nil
+	})
+}
+
+// Разбирает поддерево, составленное из имен единиц, "*", "/" и целых степеней
+func unitExpression(node *Node) (Unit, bool) {
+	switch {
+	case node.Kind == NodeVariable && IsUnit(node.Value):
+		return Unit{powers: []unitPower{{node.Value, 1}}}, true
+	case node.Kind == NodeBinary && (node.Value == "*" || node.Value == "/"):
+		left, ok := unitExpression(node.Children[0])
+		if !ok {
+			return Unit{}, false
+		}
+		right, ok := unitExpression(node.Children[1])
+		if !ok {
+			return Unit{}, false
+		}
+		if node.Value == "/" {
+			right = right.Pow(-1)
+		}
+		return left.Mul(right), true
+	case node.Kind == NodeBinary && node.Value == "^" && node.Children[1].Kind == NodeNumber:
+		base, ok := unitExpression(node.Children[0])
+		if !ok {
+			return Unit{}, false
+		}
+		power, err := strconv.Atoi(node.Children[1].Value)
+		if err != nil {
+			return Unit{}, false
+		}
+		return base.Pow(power), true
+	}
+	return Unit{}, false
+}
@@ -0,0 +1,98 @@
+package rpn
+
+import "testing"
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		unit  string
+		want  string
+		valid bool
+	}{
+		{"", "", true},
+		{"m", "m", true},
+		{"m/s^2", "m/s^2", true},
+		{"kg*m/s/s", "kg*m/s^2", true},
+		{"m*m/m", "m", true},
+		{"s^-1", "s^-1", true},
+		{"1/s", "", false},
+		{"/s", "", false},
+		{"furlong", "", false},
+		{"m/", "", false},
+		{"m^x", "", false},
+		{"m s", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			unit, err := ParseUnit(tt.unit)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ParseUnit() error = %v, want valid %v", err, tt.valid)
+			}
+			if err == nil && unit.String() != tt.want {
+				t.Errorf("ParseUnit().String() = %q, want %q", unit.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+		valid    bool
+	}{
+		{"km", "m", "1000", true},
+		{"m", "km", "1/1000", true},
+		{"km/h", "m/s", "5/18", true},
+		{"N", "kg*m/s^2", "1", true},
+		{"L", "cm^3", "1000", true},
+		{"m", "s", "", false},
+		{"Hz", "s", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" "+tt.to, func(t *testing.T) {
+			from, err := ParseUnit(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := ParseUnit(tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			factor, err := Conversion(from, to)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Conversion() error = %v, want valid %v", err, tt.valid)
+			}
+			if err == nil && factor.RatString() != tt.want {
+				t.Errorf("Conversion() = %s, want %s", factor.RatString(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitQuantity(t *testing.T) {
+	tests := []struct {
+		value     string
+		magnitude string
+		unit      string
+	}{
+		{"5", "5", ""},
+		{"2.5 m/s", "2.5", "m/s"},
+		{"[1, 2] km", "[1, 2]", "km"},
+		{"[1, 2]", "[1, 2]", ""},
+		{"-3e2 kg", "-3e2", "kg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			magnitude, unit, err := SplitQuantity(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if magnitude != tt.magnitude || unit.String() != tt.unit {
+				t.Errorf("SplitQuantity() = %q %q, want %q %q", magnitude, unit.String(), tt.magnitude, tt.unit)
+			}
+			if got := FormatQuantity(magnitude, unit); got != tt.value {
+				t.Errorf("FormatQuantity() = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
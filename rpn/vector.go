@@ -0,0 +1,57 @@
+package rpn
+
+// Функция, которая собирает вектор из своих аргументов. Литерал "[1, 2]" разбирается как вызов "vector(1, 2)",
+// а матрица "[[1, 2], [3, 4]]" - как вектор векторов
+const Vector = "vector"
+
+// Заменяет квадратные скобки вызовами функции vector: "[" - именем функции и открывающей скобкой,
+// "]" - закрывающей. Проверяет, что каждая скобка закрывается скобкой своего вида
+func expandBrackets(tokens []Token) ([]Token, error) {
+	expanded := make([]Token, 0, len(tokens))
+	var open []Token
+	for _, token := range tokens {
+		switch token.Type {
+		case TokenLeftParen:
+			open = append(open, token)
+		case TokenLeftBracket:
+			open = append(open, token)
+			expanded = append(
+				expanded,
+				Token{Type: TokenIdentifier, Text: Vector, Pos: token.Pos},
+				Token{Type: TokenLeftParen, Text: token.Text, Pos: token.Pos},
+			)
+			continue
+		case TokenRightParen, TokenRightBracket:
+			if len(open) > 0 {
+				opening := open[len(open)-1]
+				open = open[:len(open)-1]
+				if (opening.Type == TokenLeftBracket) != (token.Type == TokenRightBracket) {
+					return nil, newParseError(ErrUnmatchedParen, "Закрывающая скобка не совпадает с открывающей", token)
+				}
+			}
+			if token.Type == TokenRightBracket {
+				token = Token{Type: TokenRightParen, Text: token.Text, Pos: token.Pos}
+			}
+		}
+		expanded = append(expanded, token)
+	}
+	return expanded, nil
+}
+
+// Проверяет, что узел - литерал матрицы: вектор, все элементы которого - векторы
+func isMatrix(node *Node) bool {
+	if !isVector(node) {
+		return false
+	}
+	for _, child := range node.Children {
+		if !isVector(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// Проверяет, что узел - литерал вектора
+func isVector(node *Node) bool {
+	return node.Kind == NodeFunction && node.Value == Vector
+}
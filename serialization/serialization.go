@@ -14,44 +14,103 @@ type Message interface {
 	String() string
 }
 
-// Структура сообщения, хранящего в себе выражение
-type TaskMessage struct {
-	ID         uuid.UUID                `json:"id"`
-	Expression string                   `json:"expression"`
-	Timeouts   map[string]time.Duration `json:"timings"`
+// Структура сообщения с одной операцией выражения, которую может выполнить любой агент: номер узла в дереве
+// выражения (rpn.Node.IDs), операция, уже вычисленные операнды, режим вычислений и время операции.
+// Если поэлементная операция над векторами делится на части, Chunk - номер части (с единицы), иначе 0.
+// У if единственный операнд - значение условия
+type OperationMessage struct {
+	TaskID    uuid.UUID     `json:"task_id"`
+	Node      int           `json:"node"`
+	Chunk     int           `json:"chunk,omitempty"`
+	Operation string        `json:"operation"`
+	Operands  []string      `json:"operands"`
+	Mode      string        `json:"mode"`
+	Timeout   time.Duration `json:"timeout"`
 }
 
 // Возвращает строковое представление сообщения
-func (tm TaskMessage) String() string {
+func (om OperationMessage) String() string {
 	return fmt.Sprintf(
-		"Expression: %s; ID: %s; Timings: %v",
-		tm.Expression,
-		tm.ID.String(),
-		tm.Timeouts,
+		"TaskID: %s; Node: %d; Chunk: %d; Operation: %s; Operands: %v; Mode: %s; Timeout: %s",
+		om.TaskID.String(),
+		om.Node,
+		om.Chunk,
+		om.Operation,
+		om.Operands,
+		om.Mode,
+		om.Timeout,
 	)
 }
 
-// Структура сообщения, хранящего в себе результат выражения
+// Структура сообщения, хранящего в себе результат одной операции выражения (узла Node, части Chunk)
+// или ошибку ее вычисления вместе с трассировкой операции и айди агента, который ее выполнил.
+// Если у результата есть единица измерения, Result записан вместе с ней ("2.5 m/s"),
+// а Value и Unit хранят число и единицу по отдельности
 type ResultMessage struct {
-	ID     uuid.UUID `json:"id"`
-	Result string    `json:"result"`
-	Status string    `json:"status"`
+	ID      uuid.UUID    `json:"id"`
+	Node    int          `json:"node"`
+	Chunk   int          `json:"chunk,omitempty"`
+	AgentID uuid.UUID    `json:"agent_id"`
+	Result  string       `json:"result"`
+	Value   string       `json:"value,omitempty"`
+	Unit    string       `json:"unit,omitempty"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Trace   []TraceEntry `json:"trace,omitempty"`
 }
 
 // Возвращает строковое представление сообщения
 func (rm ResultMessage) String() string {
-	return fmt.Sprintf("ID: %s; Result: %s; Status: %s", rm.ID, rm.Result, rm.Status)
+	return fmt.Sprintf(
+		"ID: %s; Node: %d; Chunk: %d; AgentID: %s; Result: %s; Unit: %s; Status: %s; Error: %s; Trace: %d operations",
+		rm.ID,
+		rm.Node,
+		rm.Chunk,
+		rm.AgentID,
+		rm.Result,
+		rm.Unit,
+		rm.Status,
+		rm.Error,
+		len(rm.Trace),
+	)
+}
+
+// Одна операция, выполненная агентом: номер узла в дереве выражения (rpn.Node.IDs), операция, ее операнды,
+// результат или ошибка, время начала и конца, агент и слот - номер одновременно выполняемой на агенте
+// операции. Операции, которые шли параллельно на одном агенте, занимают разные слоты. У if операнд - условие,
+// а результат - "1", если выбрана первая ветка, и "0", если вторая
+type TraceEntry struct {
+	Node      int       `json:"node"`
+	Operation string    `json:"operation"`
+	Operands  []string  `json:"operands"`
+	Result    string    `json:"result,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Agent     uuid.UUID `json:"agent"`
+	Slot      int       `json:"slot"`
+	// Номер части (с единицы), если поэлементная операция над векторами вычислялась по частям, иначе 0
+	Chunk int `json:"chunk,omitempty"`
 }
 
-// Структура сообщения, хранящего в себе айди выражения и айди агента, на котором вычисляется выражение
+// Структура сообщения, хранящего в себе айди выражения, номер узла и части операции и айди агента,
+// который начал ее вычислять
 type CalculatingMessage struct {
 	AgentID uuid.UUID `json:"agent_id"`
 	TaskID  uuid.UUID `json:"task_id"`
+	Node    int       `json:"node"`
+	Chunk   int       `json:"chunk,omitempty"`
 }
 
 // Возвращает строковое представление сообщения
 func (cm CalculatingMessage) String() string {
-	return fmt.Sprintf("AgentID: %s; TaskID: %s", cm.AgentID.String(), cm.TaskID.String())
+	return fmt.Sprintf(
+		"AgentID: %s; TaskID: %s; Node: %d; Chunk: %d",
+		cm.AgentID.String(),
+		cm.TaskID.String(),
+		cm.Node,
+		cm.Chunk,
+	)
 }
 
 // Переводит сообщение в байты